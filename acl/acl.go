@@ -0,0 +1,248 @@
+// Package acl restricts sections of the site to users and groups. Rules map
+// routes to the users and groups that may access them, and the first rule
+// that matches a route decides. Routes that no rule matches are accessible
+// to everyone.
+package acl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Everyone is the name used for users without a group, like for the build of
+// the site they see. Group names can't start with an underscore, so it
+// doesn't clash with a group.
+const Everyone = "_everyone"
+
+// AnyUser in the users of a rule allows every signed-in user
+const AnyUser = "*"
+
+// User is a signed-in user, or an anonymous visitor without a name
+type User struct {
+	Name string
+	// Groups are the groups the user is a member of besides the groups of
+	// the ACL, like the groups reported by an identity provider
+	Groups []string
+}
+
+// ACL is the access control list of the site
+type ACL struct {
+	// Groups maps group names to their members
+	Groups map[string][]string `yaml:"groups"`
+	Rules  []Rule              `yaml:"rules"`
+}
+
+// Rule restricts the routes that match its path to its users and groups. A
+// path ending in a slash matches the directory and everything in it, other
+// paths match a single page or file. Every part of the path can be a glob,
+// like /teams/*/private/ or /hr/*.pdf. Pages match with or without .html.
+type Rule struct {
+	Path   string   `yaml:"path"`
+	Users  []string `yaml:"users"`
+	Groups []string `yaml:"groups"`
+
+	// segments are the parts of the path, and dir is set for paths that
+	// match everything below them
+	segments []string
+	dir      bool
+}
+
+// Load reads an ACL from a YAML file
+func Load(filename string) (*ACL, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return a, nil
+}
+
+// Parse reads an ACL from YAML
+func Parse(data []byte) (*ACL, error) {
+	var a ACL
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&a); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	for group := range a.Groups {
+		if err := validGroup(group); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range a.Rules {
+		rule := &a.Rules[i]
+
+		if !strings.HasPrefix(rule.Path, "/") {
+			return nil, fmt.Errorf("rule %d: path %q must start with /", i+1, rule.Path)
+		}
+
+		for _, group := range rule.Groups {
+			if err := validGroup(group); err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+		}
+
+		rule.dir = strings.HasSuffix(rule.Path, "/")
+		rule.segments = segments(rule.Path)
+		for _, segment := range rule.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("rule %d: path %q: %w", i+1, rule.Path, err)
+			}
+		}
+	}
+
+	return &a, nil
+}
+
+// validGroup reports whether the name of a group can be used as the name of
+// its build directory
+func validGroup(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+		return fmt.Errorf("invalid group name %q", name)
+	}
+	return nil
+}
+
+// GroupNames returns the names of the groups of the ACL and its rules, sorted
+func (a *ACL) GroupNames() []string {
+	var names []string
+	for group := range a.Groups {
+		names = append(names, group)
+	}
+	for _, rule := range a.Rules {
+		names = append(names, rule.Groups...)
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// Allowed reports whether the user may access the route
+func (a *ACL) Allowed(route string, user User) bool {
+	return a.Access(user).Allowed(route)
+}
+
+// Access returns the rules the user passes. A nil ACL allows everything.
+func (a *ACL) Access(user User) Access {
+	if a == nil {
+		return Access{}
+	}
+
+	groups := slices.Clone(user.Groups)
+	if user.Name != "" {
+		for group, members := range a.Groups {
+			if slices.Contains(members, user.Name) {
+				groups = append(groups, group)
+			}
+		}
+	}
+
+	allowed := make([]byte, len(a.Rules))
+	for i, rule := range a.Rules {
+		allowed[i] = '0'
+		if rule.allows(user.Name, groups) {
+			allowed[i] = '1'
+		}
+	}
+
+	return Access{acl: a, allowed: string(allowed)}
+}
+
+// match returns the index of the first rule that matches the route, or -1
+func (a *ACL) match(route string) int {
+	segments := segments(route)
+	for i, rule := range a.Rules {
+		if rule.matches(segments) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r Rule) matches(route []string) bool {
+	if len(route) < len(r.segments) || (!r.dir && len(route) != len(r.segments)) {
+		return false
+	}
+
+	for i, segment := range r.segments {
+		if ok, _ := path.Match(segment, route[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (r Rule) allows(name string, groups []string) bool {
+	if name != "" && (slices.Contains(r.Users, name) || slices.Contains(r.Users, AnyUser)) {
+		return true
+	}
+
+	for _, group := range groups {
+		if slices.Contains(r.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// segments returns the parts of a route, where pages with and without .html
+// and directories with and without their index page are the same. Files and
+// their precompressed .gz siblings are the same as well.
+func segments(route string) []string {
+	route = path.Clean("/" + route)
+	route = strings.TrimSuffix(route, ".gz")
+	route = strings.TrimSuffix(route, ".html")
+	if path.Base(route) == "index" {
+		route = path.Dir(route)
+	}
+
+	if route == "/" {
+		return nil
+	}
+	return strings.Split(route[1:], "/")
+}
+
+// Access is what a user may access. Users with the same access see the same
+// site. The zero Access allows everything.
+type Access struct {
+	acl *ACL
+	// allowed holds a 1 for every rule that is passed
+	allowed string
+}
+
+// Allowed reports whether the route may be accessed
+func (a Access) Allowed(route string) bool {
+	if a.acl == nil {
+		return true
+	}
+
+	i := a.acl.match(route)
+	return i < 0 || a.allowed[i] == '1'
+}
+
+// Key identifies the access among the accesses of the same ACL
+func (a Access) Key() string {
+	return a.allowed
+}
+
+// ACL returns the ACL the access was computed from, or nil for the zero
+// Access
+func (a Access) ACL() *ACL {
+	return a.acl
+}
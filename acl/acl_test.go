@@ -0,0 +1,161 @@
+package acl
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testACL = `
+groups:
+  security: [alice]
+  hr: [carol]
+rules:
+  - path: /security/public/
+    users: ["*"]
+  - path: /security/
+    groups: [security]
+  - path: /hr/*.pdf
+    users: [carol]
+  - path: /teams/*/private/
+    groups: [leads]
+  - path: /roadmap
+    users: [bob]
+`
+
+func TestAllowed(t *testing.T) {
+	a, err := Parse([]byte(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := User{Name: "alice"}
+	bob := User{Name: "bob"}
+	carol := User{Name: "carol"}
+	lead := User{Name: "dave", Groups: []string{"leads"}}
+	anonymous := User{}
+
+	tests := []struct {
+		route    string
+		user     User
+		expected bool
+	}{
+		{"/", anonymous, true},
+		{"/guide/setup", anonymous, true},
+		{"/security/", alice, true},
+		{"/security/", bob, false},
+		{"/security", bob, false},
+		{"/security/policy", alice, true},
+		{"/security/policy.html", bob, false},
+		{"/security/index.html", bob, false},
+		{"/security/img/diagram.png", bob, false},
+		{"/security/../security/policy", bob, false},
+		// The first rule that matches decides
+		{"/security/public/overview", bob, true},
+		{"/security/public/overview", anonymous, false},
+		{"/hr/salaries.pdf", carol, true},
+		{"/hr/salaries.pdf", alice, false},
+		{"/hr/handbook", alice, true},
+		{"/teams/platform/private/", lead, true},
+		{"/teams/platform/private/notes.html", bob, false},
+		{"/teams/platform/public", bob, true},
+		{"/roadmap", bob, true},
+		{"/roadmap.html", alice, false},
+		{"/roadmap.html.gz", alice, false},
+		{"/security/policy.html.gz", bob, false},
+		{"/hr/salaries.pdf.gz", alice, false},
+		{"/roadmap/2025", alice, true},
+	}
+
+	for _, tt := range tests {
+		if actual := a.Allowed(tt.route, tt.user); actual != tt.expected {
+			t.Errorf("Expected %v for '%s' and %+v, but got %v", tt.expected, tt.route, tt.user, actual)
+		}
+	}
+}
+
+func TestAccess(t *testing.T) {
+	a, err := Parse([]byte(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := a.Access(User{Name: "alice"})
+	member := a.Access(User{Name: "eve", Groups: []string{"security"}})
+	bob := a.Access(User{Name: "bob"})
+
+	if alice.Key() != member.Key() {
+		t.Errorf("Expected users passing the same rules to have the same key, but got '%s' and '%s'", alice.Key(), member.Key())
+	}
+	if alice.Key() == bob.Key() {
+		t.Errorf("Expected users passing different rules to have different keys, but both got '%s'", alice.Key())
+	}
+	if alice.ACL() != a {
+		t.Error("Expected the access to refer to its ACL")
+	}
+
+	// The zero access and the access of a nil ACL allow everything
+	var none *ACL
+	for _, access := range []Access{{}, none.Access(User{})} {
+		if !access.Allowed("/security/") {
+			t.Error("Expected the zero access to allow everything")
+		}
+	}
+}
+
+func TestGroupNames(t *testing.T) {
+	a, err := Parse([]byte(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"hr", "leads", "security"}
+	if actual := a.GroupNames(); !slices.Equal(actual, expected) {
+		t.Errorf("Expected groups %v, but got %v", expected, actual)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		acl      string
+		expected string
+	}{
+		{"rules:\n  - path: security/\n", "must start with /"},
+		{"rules:\n  - path: /[security/\n", "syntax error in pattern"},
+		{"rules:\n  - path: /hr/\n    groups: [_admins]\n", "invalid group name"},
+		{"groups:\n  a/b: [alice]\n", "invalid group name"},
+		{"rules:\n  - path: /hr/\n    user: [alice]\n", "field user not found"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.acl))
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing '%s' for %q, but got %v", tt.expected, tt.acl, err)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acl.yaml")
+	if err := os.WriteFile(path, []byte(testACL), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Rules) != 5 {
+		t.Errorf("Expected 5 rules, but got %d", len(a.Rules))
+	}
+
+	// An empty file has no rules
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if a, err := Load(path); err != nil || len(a.Rules) != 0 {
+		t.Errorf("Expected an empty ACL, but got %v, %v", a, err)
+	}
+}
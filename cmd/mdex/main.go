@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"runtime"
 	"strings"
+	"syscall"
+	"time"
 
 	mdex "github.com/jpbruinsslot/mdex"
+	"github.com/jpbruinsslot/mdex/acl"
 	"github.com/jpbruinsslot/mdex/http"
 	"github.com/jpbruinsslot/mdex/parser"
 )
@@ -25,6 +33,8 @@ WEBSITE:
 
 COMMANDS:
     generate       generate static files
+    watch          generate static files and regenerate them on changes
+    check          check markdown files for broken links
     serve          serve static files
     help           show this help message
 
@@ -32,22 +42,161 @@ OPTIONS FOR "generate":
     --parser       parser to use (default: goldmark)
     --root         root path for markdown files (default: current directory)
     --output       output path for generated files (default: ./public)
+    --drafts       include pages marked as draft (default: false)
+    --future       include pages with a publish date in the future (default: false)
+    --force        render all pages, even when they didn't change (default: false)
+    --jobs         number of pages rendered in parallel (default: number of CPUs)
+    --fail-fast    stop at the first file that fails to build, instead of
+                   reporting all of them at the end (default: false)
+    --base-url     URL the site is hosted at, like https://intranet/eng/docs/,
+                   whose path prefixes all links (optional)
+    --flat-nav     only show the current directory in the sidebar, instead
+                   of the whole site (default: false)
+    --no-search    don't generate a search index and search box (default: false)
+    --server-search
+                   have the search box use the search endpoint of "serve"
+                   instead of a search index in the browser, for large
+                   sites (default: false)
+    --acl          access rules file, to generate a build for every group
+                   in a directory named after it, and one for everyone else
+                   in _everyone, that leave out what they can't access.
+                   Its rules can only name groups (optional)
+    --precompress  write a gzip compressed .gz file next to every page,
+                   script, stylesheet and search index file, which "serve"
+                   sends instead of compressing them (default: false)
+
+OPTIONS FOR "watch":
+    accepts the same options as "generate", except --acl
+
+OPTIONS FOR "check":
+    --parser       parser to use (default: goldmark)
+    --root         root path for markdown files (default: current directory)
+    --drafts       include pages marked as draft (default: false)
+    --future       include pages with a publish date in the future (default: false)
+    --format       output format, text or json (default: text)
+    --allow-external
+                   file with allowed external URLs or hosts, one per line.
+                   External links are only checked when given, and are
+                   never requested (optional)
 
 OPTIONS FOR "serve":
     --static-root  root path to serve (default: ./public)
+    --root         render the markdown files in the root path on request,
+                   instead of serving generated files from the static root.
+                   Accepts the "generate" options --parser, --drafts,
+                   --future and --flat-nav as well (optional)
     --port         port to serve on (default: 8080)
-    --basic-auth   username:password for basic auth (optional)
+    --basic-auth   username:password for basic auth, or set it in the
+                   MDEX_BASIC_AUTH environment variable (optional)
+    --htpasswd     htpasswd file with the users for basic auth, with bcrypt,
+                   SHA-256 or SHA-512 crypt passwords, reloaded when it
+                   changes (optional)
+    --acl          access rules file, restricting paths to users and groups.
+                   With --root, pages are left out of the navigation of the
+                   users that can't access them. The search index of the
+                   browser isn't served, use --server-search (optional)
+    --trusted-proxies
+                   comma separated networks of reverse proxies, like
+                   10.0.0.0/8, whose X-Forwarded-User and X-Forwarded-Groups
+                   headers identify users (optional)
+    --proxy-user-header, --proxy-groups-header
+                   other headers of the trusted proxies with the user and
+                   their comma separated groups (optional)
+    --oidc-issuer  URL of an OpenID Connect provider to sign users in with
+                   (optional)
+    --oidc-client-id
+                   client ID registered at the provider (optional)
+    --oidc-client-secret
+                   client secret registered at the provider, or set it in
+                   the MDEX_OIDC_CLIENT_SECRET environment variable (optional)
+    --oidc-redirect-url
+                   URL the provider sends users back to (default: the base
+                   URL followed by /_mdex/auth/callback). Set
+                   MDEX_SESSION_SECRET to keep users signed in across
+                   restarts
+    --oidc-username-claim
+                   claim that users are known by in the access rules: sub,
+                   email or preferred_username. The email address is only
+                   used when the provider verified it (default: sub)
+    --shutdown-timeout
+                   how long in-flight requests are given to finish when the
+                   server stops on SIGINT or SIGTERM (default: 10s)
+    --tls-cert     certificate file to serve HTTPS with, reloaded when it
+                   changes (optional)
+    --tls-key      key file of the certificate (optional)
+    --tls-self-signed
+                   serve HTTPS with a self-signed certificate for localhost,
+                   for development (default: false)
+    --http-redirect-port
+                   port to redirect plain HTTP requests to HTTPS on
+                   (optional)
+    --base-url     URL the site is hosted at, whose path the site is served
+                   under (optional)
+    --no-compress  don't compress responses with gzip (default: false)
+    --server-search
+                   serve a search endpoint at /_mdex/search, with an index
+                   of the pages that is rebuilt when they change
+                   (default: false)
+
+OPTIONS WHEN NO COMMAND IS GIVEN:
+    accepts the options of "generate" and "serve", except --acl, and
+    --watch        regenerate static files on changes, and reload open
+                   pages in the browser (default: false)
 `
 
 var errShowUsage = fmt.Errorf("show usage")
 
+// basicAuthEnv holds username:password for basic auth when --basic-auth isn't
+// given, so the password doesn't show up in the process list
+const basicAuthEnv = "MDEX_BASIC_AUTH"
+
+// oidcClientSecretEnv holds the OpenID Connect client secret when
+// --oidc-client-secret isn't given, and sessionSecretEnv the secret that
+// session cookies are signed with
+const (
+	oidcClientSecretEnv = "MDEX_OIDC_CLIENT_SECRET"
+	sessionSecretEnv    = "MDEX_SESSION_SECRET"
+)
+
+// stdout is where commands write their results
+var stdout io.Writer = os.Stdout
+
 type commonFlags struct {
-	parserName *string
-	root       *string
-	output     *string
-	staticRoot *string
-	port       *string
-	basicAuth  *string
+	parserName        *string
+	root              *string
+	output            *string
+	staticRoot        *string
+	port              *string
+	basicAuth         *string
+	htpasswd          *string
+	acl               *string
+	trustedProxies    *string
+	proxyUserHeader   *string
+	proxyGroupsHeader *string
+	oidcIssuer        *string
+	oidcClientID      *string
+	oidcClientSecret  *string
+	oidcRedirectURL   *string
+	oidcUsernameClaim *string
+	drafts            *bool
+	future            *bool
+	watch             *bool
+	force             *bool
+	jobs              *int
+	failFast          *bool
+	baseURL           *string
+	flatNav           *bool
+	noSearch          *bool
+	precompress       *bool
+	noCompress        *bool
+	serverSearch      *bool
+	shutdownTimeout   *time.Duration
+	tlsCert           *string
+	tlsKey            *string
+	tlsSelfSigned     *bool
+	httpRedirectPort  *string
+	format            *string
+	allowExternal     *string
 }
 
 func (cf *commonFlags) parse(fs *flag.FlagSet, args []string) error {
@@ -57,9 +206,143 @@ func (cf *commonFlags) parse(fs *flag.FlagSet, args []string) error {
 	cf.staticRoot = fs.String("static-root", "./public", "path to serve")
 	cf.port = fs.String("port", "8080", "port to serve on")
 	cf.basicAuth = fs.String("basic-auth", "", "username:password for basic auth")
+	cf.htpasswd = fs.String("htpasswd", "", "htpasswd file with the users for basic auth")
+	cf.acl = fs.String("acl", "", "access rules file")
+	cf.trustedProxies = fs.String("trusted-proxies", "", "comma separated networks of proxies whose identity headers are trusted")
+	cf.proxyUserHeader = fs.String("proxy-user-header", "", "header of the trusted proxies with the user")
+	cf.proxyGroupsHeader = fs.String("proxy-groups-header", "", "header of the trusted proxies with the groups of the user")
+	cf.oidcIssuer = fs.String("oidc-issuer", "", "URL of the OpenID Connect provider")
+	cf.oidcClientID = fs.String("oidc-client-id", "", "OpenID Connect client ID")
+	cf.oidcClientSecret = fs.String("oidc-client-secret", "", "OpenID Connect client secret")
+	cf.oidcRedirectURL = fs.String("oidc-redirect-url", "", "URL the OpenID Connect provider sends users back to")
+	cf.oidcUsernameClaim = fs.String("oidc-username-claim", "sub", "claim of the ID token that users are known by")
+	cf.drafts = fs.Bool("drafts", false, "include pages marked as draft")
+	cf.future = fs.Bool("future", false, "include pages with a publish date in the future")
+	cf.force = fs.Bool("force", false, "render all pages, even when they didn't change")
+	cf.jobs = fs.Int("jobs", runtime.GOMAXPROCS(0), "number of pages rendered in parallel")
+	cf.failFast = fs.Bool("fail-fast", false, "stop at the first file that fails to build")
+	cf.baseURL = fs.String("base-url", "", "URL the site is hosted at")
+	cf.flatNav = fs.Bool("flat-nav", false, "only show the current directory in the sidebar")
+	cf.noSearch = fs.Bool("no-search", false, "don't generate a search index")
+	cf.precompress = fs.Bool("precompress", false, "write gzip compressed .gz files next to the outputs")
+	cf.noCompress = fs.Bool("no-compress", false, "don't compress responses")
+	cf.serverSearch = fs.Bool("server-search", false, "search on the server instead of in the browser")
+	cf.shutdownTimeout = fs.Duration("shutdown-timeout", 10*time.Second, "how long in-flight requests are given to finish on shutdown")
+	cf.tlsCert = fs.String("tls-cert", "", "certificate file for HTTPS")
+	cf.tlsKey = fs.String("tls-key", "", "key file for HTTPS")
+	cf.tlsSelfSigned = fs.Bool("tls-self-signed", false, "serve HTTPS with a self-signed certificate")
+	cf.httpRedirectPort = fs.String("http-redirect-port", "", "port that redirects plain HTTP to HTTPS")
+	cf.format = fs.String("format", "text", "output format of check, text or json")
+	cf.allowExternal = fs.String("allow-external", "", "file with allowed external URLs or hosts")
+	cf.watch = fs.Bool("watch", false, "regenerate static files on changes")
 	return fs.Parse(args)
 }
 
+func (cf *commonFlags) parserOptions() []parser.Option {
+	return []parser.Option{
+		parser.WithRootPath(*cf.root),
+		parser.WithOutputPath(*cf.output),
+		parser.WithDrafts(*cf.drafts),
+		parser.WithFuture(*cf.future),
+		parser.WithIncremental(!*cf.force),
+		parser.WithJobs(*cf.jobs),
+		parser.WithFailFast(*cf.failFast),
+		parser.WithBaseURL(*cf.baseURL),
+		parser.WithFlatNav(*cf.flatNav),
+		parser.WithSearch(!*cf.noSearch),
+		parser.WithServerSearch(*cf.serverSearch && !*cf.noSearch),
+		parser.WithPrecompress(*cf.precompress),
+		parser.WithExcludedFiles(cf.privateFiles()...),
+	}
+}
+
+// privateFiles returns the files of the options that must never be copied to
+// the output, in case they are kept next to the markdown files
+func (cf *commonFlags) privateFiles() []string {
+	var files []string
+	for _, file := range []string{*cf.htpasswd, *cf.acl, *cf.tlsCert, *cf.tlsKey, *cf.allowExternal} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// serverOptions returns the options of the server that serves staticRoot
+func (cf *commonFlags) serverOptions(staticRoot string) ([]http.Option, error) {
+	options := []http.Option{
+		http.WithStaticRoot(staticRoot),
+		http.WithPort(*cf.port),
+		http.WithBaseURL(*cf.baseURL),
+		http.WithShutdownTimeout(*cf.shutdownTimeout),
+		http.WithCompression(!*cf.noCompress),
+	}
+
+	basicAuth := *cf.basicAuth
+	if basicAuth == "" {
+		basicAuth = os.Getenv(basicAuthEnv)
+	}
+	if basicAuth != "" {
+		username, password, err := parseBasicAuth(basicAuth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid basic auth format: %w", err)
+		}
+		options = append(options, http.WithBasicAuth(username, password))
+	}
+	if *cf.htpasswd != "" {
+		options = append(options, http.WithHtpasswd(*cf.htpasswd))
+	}
+
+	if *cf.trustedProxies != "" {
+		options = append(options,
+			http.WithTrustedProxies(strings.Split(*cf.trustedProxies, ",")...),
+			http.WithProxyHeaders(*cf.proxyUserHeader, *cf.proxyGroupsHeader),
+		)
+	}
+	if *cf.oidcIssuer != "" || *cf.oidcClientID != "" {
+		clientSecret := *cf.oidcClientSecret
+		if clientSecret == "" {
+			clientSecret = os.Getenv(oidcClientSecretEnv)
+		}
+		options = append(options,
+			http.WithOIDC(*cf.oidcIssuer, *cf.oidcClientID, clientSecret, *cf.oidcRedirectURL),
+			http.WithOIDCUsernameClaim(*cf.oidcUsernameClaim),
+			http.WithSessionSecret(os.Getenv(sessionSecretEnv)),
+		)
+	}
+
+	if a, err := cf.loadACL(); err != nil {
+		return nil, err
+	} else if a != nil {
+		options = append(options, http.WithACL(a))
+	}
+
+	if *cf.tlsCert != "" || *cf.tlsKey != "" {
+		options = append(options, http.WithTLS(*cf.tlsCert, *cf.tlsKey))
+	}
+	if *cf.tlsSelfSigned {
+		options = append(options, http.WithSelfSignedTLS(""))
+	}
+	if *cf.httpRedirectPort != "" {
+		options = append(options, http.WithHTTPRedirect(*cf.httpRedirectPort))
+	}
+
+	return options, nil
+}
+
+// loadACL reads the access rules, or returns nil when there are none
+func (cf *commonFlags) loadACL() (*acl.ACL, error) {
+	if *cf.acl == "" {
+		return nil, nil
+	}
+
+	a, err := acl.Load(*cf.acl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access rules: %w", err)
+	}
+	return a, nil
+}
+
 func main() {
 	if err := run(os.Args[1:]); err != nil {
 		if err == errShowUsage {
@@ -83,6 +366,10 @@ func run(args []string) error {
 	switch subcommand {
 	case "generate":
 		return generateCmd(subcommandArgs)
+	case "watch":
+		return watchCmd(subcommandArgs)
+	case "check":
+		return checkCmd(subcommandArgs)
 	case "serve":
 		return serveCmd(subcommandArgs)
 	case "help":
@@ -102,13 +389,90 @@ func generateCmd(args []string) error {
 
 	md := getMarkdownParser(*cf.parserName)
 
-	err := mdex.Generate(md, parser.WithRootPath(*cf.root), parser.WithOutputPath(*cf.output))
+	a, err := cf.loadACL()
+	if err != nil {
+		return err
+	}
+
+	if a != nil {
+		err = mdex.GenerateForGroups(md, a, cf.parserOptions()...)
+	} else {
+		err = mdex.Generate(md, cf.parserOptions()...)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to generate: %w", err)
 	}
 	return nil
 }
 
+func watchCmd(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	cf := &commonFlags{}
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *cf.acl != "" {
+		return fmt.Errorf("--acl can't be used with watch")
+	}
+
+	md := getMarkdownParser(*cf.parserName)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := mdex.Watch(ctx, md, cf.parserOptions()...); err != nil {
+		return fmt.Errorf("failed to watch: %w", err)
+	}
+	return nil
+}
+
+func checkCmd(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	cf := &commonFlags{}
+	if err := cf.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *cf.format != "text" && *cf.format != "json" {
+		return fmt.Errorf("unknown format %q, expected text or json", *cf.format)
+	}
+
+	md := getMarkdownParser(*cf.parserName)
+
+	opts := cf.parserOptions()
+	if *cf.allowExternal != "" {
+		allowlist, err := parser.ReadAllowlist(*cf.allowExternal)
+		if err != nil {
+			return fmt.Errorf("failed to read allowlist: %w", err)
+		}
+		opts = append(opts, parser.WithExternalAllowlist(allowlist))
+	}
+
+	report, err := mdex.Check(md, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to check: %w", err)
+	}
+
+	if *cf.format == "json" {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Fprintln(stdout, issue)
+		}
+		fmt.Fprintf(stdout, "Checked %d links in %d pages, found %d broken\n", report.Links, report.Pages, len(report.Issues))
+	}
+
+	if len(report.Issues) > 0 {
+		return fmt.Errorf("found %d broken links", len(report.Issues))
+	}
+	return nil
+}
+
 func serveCmd(args []string) error {
 	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
 	cf := &commonFlags{}
@@ -116,17 +480,27 @@ func serveCmd(args []string) error {
 		return err
 	}
 
-	options := []http.Option{
-		http.WithStaticRoot(*cf.staticRoot),
-		http.WithPort(*cf.port),
+	options, err := cf.serverOptions(*cf.staticRoot)
+	if err != nil {
+		return err
 	}
+	options = append(options, http.WithSearch(*cf.serverSearch))
 
-	if *cf.basicAuth != "" {
-		username, password, err := parseBasicAuth(*cf.basicAuth)
-		if err != nil {
-			return fmt.Errorf("invalid basic auth format: %w", err)
+	// With a root path the pages are rendered on request
+	if isFlagSet(fs, "root") {
+		md := getMarkdownParser(*cf.parserName)
+
+		// The search index is written by generate
+		parserOpts := append(cf.parserOptions(), parser.WithSearch(false), parser.WithServerSearch(false))
+
+		// Pages are rendered for anonymous users, unless they sign in
+		if a, err := cf.loadACL(); err != nil {
+			return err
+		} else if a != nil {
+			parserOpts = append(parserOpts, parser.WithAccess(a.Access(acl.User{})))
 		}
-		options = append(options, http.WithBasicAuth(username, password))
+
+		return mdex.RenderAndServe(md, parserOpts, options)
 	}
 
 	return mdex.Serve(options...)
@@ -139,29 +513,42 @@ func defaultCmd(args []string) error {
 		return err
 	}
 
+	// Generating and serving a single site would show restricted pages in
+	// the navigation of everyone
+	if *cf.acl != "" {
+		return fmt.Errorf("--acl can't be used without a command, use generate for a build per group, or serve --root")
+	}
+
 	md := getMarkdownParser(*cf.parserName)
 
-	parserOpts := []parser.Option{
-		parser.WithRootPath(*cf.root),
-		parser.WithOutputPath(*cf.output),
-	}
+	parserOpts := cf.parserOptions()
 
-	serverOpts := []http.Option{
-		http.WithStaticRoot(*cf.output),
-		http.WithPort(*cf.port),
+	serverOpts, err := cf.serverOptions(*cf.output)
+	if err != nil {
+		return err
 	}
+	serverOpts = append(serverOpts, http.WithSearch(*cf.serverSearch && !*cf.noSearch))
 
-	if *cf.basicAuth != "" {
-		username, password, err := parseBasicAuth(*cf.basicAuth)
-		if err != nil {
-			return fmt.Errorf("invalid basic auth format: %w", err)
-		}
-		serverOpts = append(serverOpts, http.WithBasicAuth(username, password))
+	if *cf.watch {
+		parserOpts = append(parserOpts, parser.WithLiveReload(true))
+		serverOpts = append(serverOpts, http.WithLiveReload(true))
+		return mdex.WatchAndServe(md, parserOpts, serverOpts)
 	}
 
 	return mdex.GenerateAndServe(md, parserOpts, serverOpts)
 }
 
+// isFlagSet reports whether the flag was given on the command line
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func parseBasicAuth(basicAuth string) (string, string, error) {
 	parts := strings.SplitN(basicAuth, ":", 2)
 	if len(parts) != 2 {
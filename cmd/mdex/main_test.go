@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"io"
 	"net/http"
 	"os"
@@ -10,6 +13,7 @@ import (
 	"time"
 
 	mdex "github.com/jpbruinsslot/mdex"
+	mdexhttp "github.com/jpbruinsslot/mdex/http"
 	"github.com/jpbruinsslot/mdex/parser"
 )
 
@@ -56,6 +60,78 @@ func TestRunGenerate(t *testing.T) {
 	}
 }
 
+func TestRunGenerateDrafts(t *testing.T) {
+	rootDir := t.TempDir()
+
+	mdFile := filepath.Join(rootDir, "draft.md")
+	err := os.WriteFile(mdFile, []byte("---\ndraft: true\n---\n# Draft"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without --drafts the draft is skipped
+	outputDir := t.TempDir()
+	if err := run([]string{"generate", "--root", rootDir, "--output", outputDir}); err != nil {
+		t.Fatalf("run generate command failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "draft.html")); err == nil {
+		t.Error("Expected draft not to be generated without --drafts")
+	}
+
+	// With --drafts the draft is generated
+	outputDir = t.TempDir()
+	if err := run([]string{"generate", "--root", rootDir, "--output", outputDir, "--drafts"}); err != nil {
+		t.Fatalf("run generate command failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "draft.html")); err != nil {
+		t.Errorf("Expected draft to be generated with --drafts: %v", err)
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	rootDir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(rootDir, "index.md"), []byte("# Home\n\n[missing](missing.md)"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	// Broken links make the command fail
+	err = run([]string{"check", "--root", rootDir})
+	if err == nil {
+		t.Fatal("Expected an error for a broken link, but got nil")
+	}
+	if !strings.Contains(out.String(), "index.md:3: missing-page:") {
+		t.Errorf("Expected output to report the broken link, but got '%s'", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"check", "--root", rootDir, "--format", "json"}); err == nil {
+		t.Fatal("Expected an error for a broken link, but got nil")
+	}
+
+	var report parser.CheckReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Expected JSON output, but got '%s': %v", out.String(), err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Link != "missing.md" {
+		t.Errorf("Expected one issue for 'missing.md', but got %v", report.Issues)
+	}
+
+	// Fixing the link makes the command succeed
+	err = os.WriteFile(filepath.Join(rootDir, "missing.md"), []byte("# Missing"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"check", "--root", rootDir}); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+}
+
 func TestRunServe(t *testing.T) {
 	// Create temporary directories for root and output
 	rootDir, err := os.MkdirTemp("", "mdex-cmd-serve-root")
@@ -141,3 +217,90 @@ func TestRunServe(t *testing.T) {
 		// Server is still running, which is expected
 	}
 }
+
+func TestServerOptionsBasicAuth(t *testing.T) {
+	tests := []struct {
+		args     []string
+		env      string
+		username string
+		password string
+	}{
+		{nil, "", "", ""},
+		{[]string{"--basic-auth", "user:pass"}, "", "user", "pass"},
+		{nil, "env:secret:with:colons", "env", "secret:with:colons"},
+		// The flag takes precedence over the environment
+		{[]string{"--basic-auth", "user:pass"}, "env:secret", "user", "pass"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv(basicAuthEnv, tt.env)
+
+		cf := &commonFlags{}
+		fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+		if err := cf.parse(fs, append(tt.args, "--htpasswd", "users.htpasswd")); err != nil {
+			t.Fatal(err)
+		}
+
+		serverOptions, err := cf.serverOptions(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		options := &mdexhttp.Options{}
+		for _, opt := range serverOptions {
+			opt(options)
+		}
+
+		if options.Username != tt.username || options.Password != tt.password {
+			t.Errorf("Expected %s:%s for %v and %q, but got %s:%s", tt.username, tt.password, tt.args, tt.env, options.Username, options.Password)
+		}
+		if options.HtpasswdFile != "users.htpasswd" {
+			t.Errorf("Expected the htpasswd file 'users.htpasswd', but got '%s'", options.HtpasswdFile)
+		}
+	}
+
+	t.Setenv(basicAuthEnv, "no-colon")
+	cf := &commonFlags{}
+	if err := cf.parse(flag.NewFlagSet("serve", flag.ContinueOnError), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cf.serverOptions(t.TempDir()); err == nil {
+		t.Errorf("Expected an error for an invalid %s", basicAuthEnv)
+	}
+}
+
+func TestServerOptionsAuth(t *testing.T) {
+	t.Setenv(oidcClientSecretEnv, "s3cret")
+	t.Setenv(sessionSecretEnv, "session")
+
+	cf := &commonFlags{}
+	args := []string{
+		"--trusted-proxies", "10.0.0.0/8,192.0.2.1",
+		"--proxy-user-header", "Remote-User",
+		"--oidc-issuer", "https://idp.example.com",
+		"--oidc-client-id", "mdex",
+	}
+	if err := cf.parse(flag.NewFlagSet("serve", flag.ContinueOnError), args); err != nil {
+		t.Fatal(err)
+	}
+
+	serverOptions, err := cf.serverOptions(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := &mdexhttp.Options{}
+	for _, opt := range serverOptions {
+		opt(options)
+	}
+
+	if strings.Join(options.TrustedProxies, " ") != "10.0.0.0/8 192.0.2.1" || options.ProxyUserHeader != "Remote-User" {
+		t.Errorf("Expected the trusted proxies and their user header, but got %v and '%s'", options.TrustedProxies, options.ProxyUserHeader)
+	}
+	if options.OIDC.Issuer != "https://idp.example.com" || options.OIDC.ClientID != "mdex" || options.OIDC.ClientSecret != "s3cret" {
+		t.Errorf("Expected the OpenID Connect provider with the secret of %s, but got %+v", oidcClientSecretEnv, options.OIDC)
+	}
+	if options.SessionSecret != "session" {
+		t.Errorf("Expected the session secret of %s, but got '%s'", sessionSecretEnv, options.SessionSecret)
+	}
+}
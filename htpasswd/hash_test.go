@@ -0,0 +1,72 @@
+package htpasswd
+
+import (
+	"strings"
+	"testing"
+)
+
+// The hashes were generated with crypt(3) of libxcrypt, which htpasswd uses
+// for SHA-256 and SHA-512 crypt, and OpenSSL
+var hashTests = []struct {
+	password string
+	hash     string
+}{
+	{"secret", "$5$saltsalt$0IyaXrmV7.sGNS6tirgqHLqX/G.FBvgkYA.lpPdS5sA"},
+	{"secret", "$6$saltsalt$TVLlQcbpFVof5W3Yz4DTP6gRstiNuHwwTt6GLc1E5n0U0aDehy0S5knV8wiOQSpT0Y77vwPZN.Pq.H91p5hVO1"},
+	{"secret", "$5$rounds=1000$saltsalt$eKLZU9t9OoPWrqOQsoTIKG0aYkZ5rGOoOQhiIvoSWX2"},
+	{"", "$6$rounds=1200$abc$7v12wPUC6fw5ZdCeWW3JgxlAV/jIg2r6jDH1dGWk2YuUGN4HIweYeLyrL28uk2vBjHxbGPbOGT9XlL32RzEbr."},
+	{"a much longer password than usual, over sixty four bytes long!!!!!", "$6$0123456789abcdef$.UCg2NBoV1fWxAyLnP8YhFUArwKZAMuV6ZGyeJ2/.mY.yXX9N9Fwh7g8aQyinBjYnxKUpTcVu0ux2dxMD7/FT1"},
+	{"pässwörd", "$5$x$Mf0xB8lGxJG3Vu9eJMicE4lrLk3adpWzOQ3sofvKXx3"},
+	{"U*U", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+	{"", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.7uG0VCzI2bS7j6ymqJi9CdcdxiRTWNy"},
+	{"secret", "$2y$04$abcdefghijklmnopqrstuu2r9OfJnfCsdneAXAGHnS4UpFFP8WIrW"},
+	// Only the first 72 bytes are used
+	{strings.Repeat("x", 80), "$2b$04$abcdefghijklmnopqrstuubzadhGtS2zEF.gu0yd0opP6cVzb.e0i"},
+	{"pässwörd", "$2b$06$0123456789ABCDEFGHIJKu4I9nw.scBXL1b6MdO2RMJFUPlOexntK"},
+	{"secret", "$2y$05$abcdefghijklmnopqrstuuOQiyCxlgf/oeuTqixKmWdcYUh4Hjl0a"},
+	{"secret", "$5$rounds=5000$Iqh6CjHlOmcpANGA$X7Hqfxqgs/uSQK3GN5HH5MhFyMQVNjxPKLzrN.j/1e9"},
+	{"secret", "$6$rounds=10000$rndsalt$sTcLKO1QOsx9h6/osiCDqQN0H3Xzss.g51ChJ8mNj1j.h0CfCaE7pKiLyLJe6llTMr3xM4RD2tD/QGEcrBR5l1"},
+}
+
+func TestVerify(t *testing.T) {
+	for _, tt := range hashTests {
+		if !Verify(tt.hash, tt.password) {
+			t.Errorf("Expected '%s' to match '%s'", tt.password, tt.hash)
+		}
+		if Verify(tt.hash, "!"+tt.password) {
+			t.Errorf("Expected '!%s' not to match '%s'", tt.password, tt.hash)
+		}
+	}
+
+	for _, hash := range []string{"", "secret", "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", "$apr1$salt$hash"} {
+		if Verify(hash, "secret") {
+			t.Errorf("Expected '%s' not to match", hash)
+		}
+	}
+}
+
+func TestCheckHash(t *testing.T) {
+	// The dummy hash has to be verified like the others to take as long
+	if err := checkHash(dummyHash); err != nil {
+		t.Errorf("Expected the dummy hash to be supported, but got %v", err)
+	}
+
+	for _, tt := range hashTests {
+		if err := checkHash(tt.hash); err != nil {
+			t.Errorf("Expected '%s' to be supported, but got %v", tt.hash, err)
+		}
+	}
+
+	tests := []string{
+		"$2y$31$abcdefghijklmnopqrstuu2r9OfJnfCsdneAXAGHnS4UpFFP8WIrW",
+		"$2y$04$short",
+		"$6$rounds=999999999$saltsalt$TVLlQcbpFVof5W3Yz4DTP6gRstiNuHwwTt6GLc1E5n0U0aDehy0S5knV8wiOQSpT0Y77vwPZN.Pq.H91p5hVO1",
+		"$apr1$salt$hash",
+		"{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=",
+	}
+	for _, hash := range tests {
+		if err := checkHash(hash); err == nil {
+			t.Errorf("Expected '%s' to be unsupported, but got no error", hash)
+		}
+	}
+}
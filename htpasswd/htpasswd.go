@@ -0,0 +1,217 @@
+// Package htpasswd reads htpasswd files, whose lines hold a user name and a
+// password hash separated by a colon. Passwords hashed with SHA-256 crypt
+// ($5$), SHA-512 crypt ($6$) and bcrypt ($2a$, $2b$ and $2y$) are supported.
+package htpasswd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GehirnInc/crypt"
+	"github.com/GehirnInc/crypt/sha256_crypt"
+	"github.com/GehirnInc/crypt/sha512_crypt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limits of the cost of the hashes, above which a single sign in would keep
+// the server busy for too long. They are the limits of htpasswd -C and
+// crypt(3) defaults many times over.
+const (
+	maxBcryptCost = 17
+	maxRounds     = 1_000_000
+)
+
+// dummyHash is verified for users that aren't in the file, so they take as
+// long as users with a wrong password and can't be told apart
+const dummyHash = "$2a$10$x0d8jickP/edXh/EW7Gt/.a5oZeQpmusRn1Ncd4ytrLqsYCm1koru"
+
+// crypter returns the SHA-crypt implementation of the hash, or nil for other
+// hashes
+func crypter(hashed string) crypt.Crypter {
+	switch {
+	case strings.HasPrefix(hashed, "$5$"):
+		return sha256_crypt.New()
+	case strings.HasPrefix(hashed, "$6$"):
+		return sha512_crypt.New()
+	}
+	return nil
+}
+
+// isBcrypt reports whether the hash is a bcrypt hash, as written by htpasswd -B
+func isBcrypt(hashed string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hashed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHash reports whether the hash is supported, without hashing anything
+func checkHash(hashed string) error {
+	if isBcrypt(hashed) {
+		cost, err := bcrypt.Cost([]byte(hashed))
+		if err != nil {
+			return err
+		}
+		if cost > maxBcryptCost {
+			return fmt.Errorf("bcrypt cost %d is above %d", cost, maxBcryptCost)
+		}
+		return nil
+	}
+
+	if c := crypter(hashed); c != nil {
+		rounds, err := c.Cost(hashed)
+		if err != nil {
+			return err
+		}
+		if rounds > maxRounds {
+			return fmt.Errorf("%d rounds is above %d", rounds, maxRounds)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("use bcrypt, SHA-256 or SHA-512 crypt")
+}
+
+// Verify reports whether the password matches the hash
+func Verify(hashed, password string) bool {
+	if isBcrypt(hashed) {
+		return bcrypt.CompareHashAndPassword([]byte(hashed), []byte(password)) == nil
+	}
+	if c := crypter(hashed); c != nil {
+		// Only the settings are passed, since Generate takes the hash for part
+		// of the salt when the rounds are given
+		settings := hashed[:strings.LastIndex(hashed, "$")]
+		actual, err := c.Generate([]byte(password), []byte(settings))
+		return err == nil && subtle.ConstantTimeCompare([]byte(actual), []byte(hashed)) == 1
+	}
+	return false
+}
+
+// File is an htpasswd file. Reload reads it again when it changed.
+type File struct {
+	Path string
+
+	mu      sync.Mutex
+	users   map[string]string
+	modTime time.Time
+	size    int64
+
+	// verified holds the digests of the passwords that matched, so they
+	// aren't hashed again on every request, which is slow by design
+	verified map[string]verifiedPassword
+}
+
+type verifiedPassword struct {
+	hash   string
+	digest [sha256.Size]byte
+}
+
+// Open reads the htpasswd file at path
+func Open(path string) (*File, error) {
+	f := &File{Path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reads the file again when it changed since it was last read, and
+// reports whether it did. When the file can't be read, the users that were
+// read before are kept. The file is read without holding up Verify.
+func (f *File) Reload() (bool, error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	unchanged := f.users != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	f.mu.Unlock()
+	if unchanged {
+		return false, nil
+	}
+
+	content, err := os.ReadFile(f.Path)
+	if err != nil {
+		return false, err
+	}
+
+	users, err := parse(content)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", f.Path, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.users = users
+	f.modTime = info.ModTime()
+	f.size = info.Size()
+	f.verified = make(map[string]verifiedPassword)
+	return true, nil
+}
+
+// Verify reports whether the user is in the file and the password matches
+func (f *File) Verify(user, password string) bool {
+	f.mu.Lock()
+	hashed, ok := f.users[user]
+	verified, cached := f.verified[user]
+	f.mu.Unlock()
+
+	if !ok {
+		Verify(dummyHash, password)
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(password))
+	if cached && verified.hash == hashed {
+		return subtle.ConstantTimeCompare(digest[:], verified.digest[:]) == 1
+	}
+
+	if !Verify(hashed, password) {
+		return false
+	}
+
+	f.mu.Lock()
+	f.verified[user] = verifiedPassword{hash: hashed, digest: digest}
+	f.mu.Unlock()
+
+	return true
+}
+
+// parse reads the users and their password hashes. Empty lines and lines
+// starting with # are skipped. Only the format of the hashes is checked,
+// passwords are hashed by Verify.
+func parse(content []byte) (map[string]string, error) {
+	users := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		user, hashed, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("line %d: expected user:hash", n)
+		}
+
+		if err := checkHash(hashed); err != nil {
+			return nil, fmt.Errorf("line %d: unsupported password hash for %s: %w", n, user, err)
+		}
+
+		users[user] = hashed
+	}
+
+	return users, scanner.Err()
+}
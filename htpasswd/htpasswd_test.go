@@ -0,0 +1,132 @@
+package htpasswd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeFile(t, path, strings.Join([]string{
+		"# Users of the docs",
+		"alice:$2y$04$abcdefghijklmnopqrstuu2r9OfJnfCsdneAXAGHnS4UpFFP8WIrW",
+		"",
+		"bob:$6$saltsalt$TVLlQcbpFVof5W3Yz4DTP6gRstiNuHwwTt6GLc1E5n0U0aDehy0S5knV8wiOQSpT0Y77vwPZN.Pq.H91p5hVO1",
+	}, "\n"), time.Now().Add(-time.Hour))
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user     string
+		password string
+		expected bool
+	}{
+		{"alice", "secret", true},
+		{"bob", "secret", true},
+		{"alice", "wrong", false},
+		{"carol", "secret", false},
+		// Unknown users are checked against the dummy hash, but never match
+		{"carol", "mdex-unknown-user", false},
+		{"", "", false},
+	}
+
+	// Twice, so the cached verifications are checked as well
+	for i := 0; i < 2; i++ {
+		for _, tt := range tests {
+			if actual := f.Verify(tt.user, tt.password); actual != tt.expected {
+				t.Errorf("Expected %v for %s:%s, but got %v", tt.expected, tt.user, tt.password, actual)
+			}
+		}
+	}
+}
+
+func TestFile_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	writeFile(t, path, "alice:$5$saltsalt$0IyaXrmV7.sGNS6tirgqHLqX/G.FBvgkYA.lpPdS5sA\n", time.Now().Add(-time.Hour))
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Verify("alice", "secret") {
+		t.Fatal("Expected alice to be verified")
+	}
+
+	if changed, err := f.Reload(); changed || err != nil {
+		t.Errorf("Expected an unchanged file not to be reloaded, but got %v, %v", changed, err)
+	}
+
+	// Alice changed her password, and bob was added
+	writeFile(t, path, strings.Join([]string{
+		"alice:$2b$04$abcdefghijklmnopqrstuubzadhGtS2zEF.gu0yd0opP6cVzb.e0i",
+		"bob:$5$saltsalt$0IyaXrmV7.sGNS6tirgqHLqX/G.FBvgkYA.lpPdS5sA",
+	}, "\n"), time.Now())
+
+	if changed, err := f.Reload(); !changed || err != nil {
+		t.Fatalf("Expected the changed file to be reloaded, but got %v, %v", changed, err)
+	}
+	if f.Verify("alice", "secret") {
+		t.Error("Expected the old password of alice to be rejected")
+	}
+	if !f.Verify("alice", strings.Repeat("x", 72)) {
+		t.Error("Expected the new password of alice to be verified")
+	}
+	if !f.Verify("bob", "secret") {
+		t.Error("Expected bob to be verified")
+	}
+
+	// A broken file keeps the users that were read before
+	writeFile(t, path, "carol\n", time.Now().Add(time.Hour))
+
+	if _, err := f.Reload(); err == nil {
+		t.Error("Expected an error for a broken file")
+	}
+	if !f.Verify("bob", "secret") {
+		t.Error("Expected bob to be verified after a broken reload")
+	}
+}
+
+func TestOpen_Errors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"alice\n", "line 1: expected user:hash"},
+		{"# comment\n:$5$saltsalt$0IyaXrmV7.sGNS6tirgqHLqX/G.FBvgkYA.lpPdS5sA\n", "line 2: expected user:hash"},
+		{"alice:$apr1$salt$hash\n", "line 1: unsupported password hash for alice"},
+		{"alice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n", "unsupported password hash"},
+		{"alice:plain\n", "unsupported password hash"},
+	}
+
+	for i, tt := range tests {
+		path := filepath.Join(dir, "htpasswd")
+		writeFile(t, path, tt.content, time.Now().Add(time.Duration(i)*time.Second))
+
+		_, err := Open(path)
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("Expected error containing '%s' for %q, but got %v", tt.expected, tt.content, err)
+		}
+	}
+
+	if _, err := Open(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
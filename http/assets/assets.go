@@ -6,4 +6,5 @@ import (
 
 //go:embed css/*
 //go:embed img/*
+//go:embed js/*
 var FS embed.FS
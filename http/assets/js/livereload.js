@@ -0,0 +1,74 @@
+// Reloads the page when mdex regenerates the site. When only the content of
+// the current page changed, the article and table of contents are swapped in
+// place, otherwise the page reloads and keeps its scroll position.
+(() => {
+  const scrollKey = "mdex-scroll:" + location.pathname;
+  const basePath = document.currentScript.dataset.basePath || "";
+
+  function normalize(path) {
+    path = path.replace(/\.html$/, "");
+    if (path.endsWith("/index")) {
+      path = path.slice(0, -"index".length);
+    }
+    return path || "/";
+  }
+
+  function restoreScroll() {
+    const saved = sessionStorage.getItem(scrollKey);
+    if (saved !== null) {
+      sessionStorage.removeItem(scrollKey);
+      window.scrollTo(0, parseInt(saved, 10));
+    }
+  }
+
+  function reload() {
+    sessionStorage.setItem(scrollKey, String(window.scrollY));
+    location.reload();
+  }
+
+  async function swapContent() {
+    const response = await fetch(location.href, { cache: "no-store" });
+    if (!response.ok) {
+      throw new Error("failed to fetch " + location.href);
+    }
+
+    const doc = new DOMParser().parseFromString(await response.text(), "text/html");
+    for (const selector of ["article", "#toc"]) {
+      const current = document.querySelector(selector);
+      const updated = doc.querySelector(selector);
+      if (current && updated) {
+        current.innerHTML = updated.innerHTML;
+      }
+    }
+    document.title = doc.title;
+  }
+
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", restoreScroll);
+  } else {
+    restoreScroll();
+  }
+
+  // Routes in events don't include the base path
+  let path = location.pathname;
+  if (basePath && path.startsWith(basePath)) {
+    path = path.slice(basePath.length);
+  }
+
+  const route = normalize(path);
+  const events = new EventSource(basePath + "/_mdex/events");
+
+  events.addEventListener("reload", (e) => {
+    const event = JSON.parse(e.data);
+    const routes = (event.routes || []).map(normalize);
+    if (!event.all && !routes.includes(route)) {
+      return;
+    }
+
+    if (event.all || event.structural) {
+      reload();
+    } else {
+      swapContent().catch(reload);
+    }
+  });
+})();
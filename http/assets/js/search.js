@@ -0,0 +1,259 @@
+// Searches the index that mdex generates in _search, in the browser, so it
+// also works when the site is hosted without mdex serve. The terms of the
+// index are sharded by their first two characters and the pages are split in
+// chunks, which are only loaded when a query needs them. With server search
+// the queries go to the search endpoint of mdex serve instead.
+(() => {
+  const basePath = document.currentScript.dataset.basePath || "";
+  const serverSearch = "serverSearch" in document.currentScript.dataset;
+  const indexPath = basePath + "/_search/";
+  const maxResults = 10;
+
+  const input = document.getElementById("search-input");
+  const results = document.getElementById("search-results");
+  if (!input || !results) {
+    return;
+  }
+
+  // Without a server there is no results page to submit the query to
+  if (!serverSearch) {
+    input.form?.addEventListener("submit", (event) => event.preventDefault());
+  }
+
+  const cache = new Map();
+  let index = null;
+  let selected = -1;
+  let query = 0;
+
+  function load(path) {
+    if (!cache.has(path)) {
+      cache.set(
+        path,
+        fetch(indexPath + path).then((response) => {
+          if (!response.ok) {
+            throw new Error(`search: failed to load ${path}: ${response.status}`);
+          }
+          return response.json();
+        }),
+      );
+    }
+    return cache.get(path);
+  }
+
+  // Splits text in lowercase terms of at least two letters or digits, like
+  // mdex does when it builds the index
+  function tokenize(text) {
+    return text
+      .toLowerCase()
+      .split(/[^\p{L}\p{N}]+/u)
+      .filter((term) => Array.from(term).length >= 2);
+  }
+
+  function shardName(term) {
+    const prefix = Array.from(term).slice(0, 2).join("");
+    return Array.from(new TextEncoder().encode(prefix))
+      .map((b) => b.toString(16).padStart(2, "0"))
+      .join("");
+  }
+
+  // Returns the scores of the pages that contain the term. The last term of
+  // the query is still being typed, so it also matches longer terms.
+  async function scoresFor(term, prefix) {
+    const scores = new Map();
+
+    const name = shardName(term);
+    if (!index.shards.includes(name)) {
+      return scores;
+    }
+
+    const shard = await load(`terms/${name}.json`);
+    for (const [candidate, postings] of Object.entries(shard)) {
+      if (candidate !== term && !(prefix && candidate.startsWith(term))) {
+        continue;
+      }
+
+      // Postings are pairs of page number and weight
+      for (let i = 0; i < postings.length; i += 2) {
+        scores.set(postings[i], (scores.get(postings[i]) || 0) + postings[i + 1]);
+      }
+    }
+
+    return scores;
+  }
+
+  async function page(id) {
+    const chunk = await load(`docs/${Math.floor(id / index.chunkSize)}.json`);
+    return chunk[id % index.chunkSize];
+  }
+
+  // Returns the results of the search endpoint. The last term of the query is
+  // still being typed, so it also matches longer terms.
+  async function searchServer(text) {
+    const query = /[\p{L}\p{N}]$/u.test(text) ? text + "*" : text;
+    const response = await fetch(
+      `${basePath}/_mdex/search?format=json&q=${encodeURIComponent(query)}`,
+    );
+    if (!response.ok) {
+      throw new Error(`search: failed to search: ${response.status}`);
+    }
+    const body = await response.json();
+    return body.results.slice(0, maxResults);
+  }
+
+  // Returns the pages that contain all terms of the query, best first
+  async function search(text) {
+    if (serverSearch) {
+      return searchServer(text);
+    }
+
+    index = index || (await load("index.json"));
+
+    const terms = tokenize(text);
+    if (terms.length === 0) {
+      return [];
+    }
+
+    let scores = null;
+    for (const [i, term] of terms.entries()) {
+      const termScores = await scoresFor(term, i === terms.length - 1);
+      if (scores === null) {
+        scores = termScores;
+        continue;
+      }
+
+      for (const [id, score] of scores) {
+        if (termScores.has(id)) {
+          scores.set(id, score + termScores.get(id));
+        } else {
+          scores.delete(id);
+        }
+      }
+    }
+
+    const ids = [...scores]
+      .sort((a, b) => b[1] - a[1] || a[0] - b[0])
+      .slice(0, maxResults)
+      .map(([id]) => id);
+
+    return Promise.all(ids.map(page));
+  }
+
+  function render(pages) {
+    selected = -1;
+
+    if (pages.length === 0) {
+      const empty = document.createElement("li");
+      empty.className = "empty";
+      empty.textContent = "No results";
+      results.replaceChildren(empty);
+      results.hidden = false;
+      return;
+    }
+
+    results.replaceChildren(
+      ...pages.map((page) => {
+        const item = document.createElement("li");
+        const link = document.createElement("a");
+        link.href = basePath + page.url;
+
+        const title = document.createElement("span");
+        title.className = "title";
+        title.textContent = page.title;
+        link.append(title);
+
+        if (page.excerpt) {
+          const excerpt = document.createElement("span");
+          excerpt.className = "excerpt";
+          excerpt.textContent = page.excerpt;
+          link.append(excerpt);
+        }
+
+        item.append(link);
+        return item;
+      }),
+    );
+    results.hidden = false;
+  }
+
+  function hide() {
+    results.hidden = true;
+    selected = -1;
+  }
+
+  function select(offset) {
+    const links = results.querySelectorAll("a");
+    if (links.length === 0) {
+      return;
+    }
+
+    links[selected]?.classList.remove("selected");
+    selected = (selected + offset + links.length) % links.length;
+    links[selected].classList.add("selected");
+    links[selected].scrollIntoView({ block: "nearest" });
+  }
+
+  let timer;
+  input.addEventListener("input", () => {
+    clearTimeout(timer);
+    timer = setTimeout(async () => {
+      const text = input.value.trim();
+      const current = ++query;
+
+      if (text === "") {
+        hide();
+        return;
+      }
+
+      try {
+        const pages = await search(text);
+        // Only show the results of the latest query
+        if (current === query) {
+          render(pages);
+        }
+      } catch (err) {
+        console.error(err);
+      }
+    }, 150);
+  });
+
+  input.addEventListener("keydown", (event) => {
+    switch (event.key) {
+      case "ArrowDown":
+        event.preventDefault();
+        select(1);
+        break;
+      case "ArrowUp":
+        event.preventDefault();
+        select(-1);
+        break;
+      case "Enter": {
+        const links = results.querySelectorAll("a");
+        // With server search, the query is submitted to the results page
+        // unless a result is selected
+        const link = links[serverSearch ? selected : Math.max(selected, 0)];
+        if (link) {
+          event.preventDefault();
+          location.href = link.href;
+        }
+        break;
+      }
+      case "Escape":
+        input.value = "";
+        hide();
+        break;
+    }
+  });
+
+  // Clicking outside of the search closes the results
+  document.addEventListener("click", (event) => {
+    if (!event.target.closest(".search")) {
+      hide();
+    }
+  });
+
+  input.addEventListener("focus", () => {
+    if (input.value.trim() !== "" && results.children.length > 0) {
+      results.hidden = false;
+    }
+  });
+})();
@@ -0,0 +1,332 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/oidc"
+)
+
+// Paths of the OpenID Connect sign in, under the base path
+const (
+	loginPath    = "/_mdex/auth/login"
+	callbackPath = "/_mdex/auth/callback"
+	logoutPath   = "/_mdex/auth/logout"
+)
+
+const (
+	sessionCookie = "mdex_session"
+	// stateCookie holds the state of a sign in, until the provider sends
+	// the user back
+	stateCookie = "mdex_oidc_state"
+
+	sessionLifetime = 12 * time.Hour
+	stateLifetime   = 10 * time.Minute
+)
+
+// Default headers that a trusted proxy identifies users with
+const (
+	DefaultProxyUserHeader   = "X-Forwarded-User"
+	DefaultProxyGroupsHeader = "X-Forwarded-Groups"
+)
+
+// session is the signed-in user of an OpenID Connect session
+type session struct {
+	User   string   `json:"user"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// signIn is the state of a sign in, kept in a cookie until the provider sends
+// the user back
+type signIn struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	// Next is where the user is sent after signing in
+	Next string `json:"next"`
+}
+
+// proxyUser returns the user identified by the headers of a trusted proxy.
+// The headers of other clients are ignored.
+func (srv *HTTPServer) proxyUser(r *http.Request) (acl.User, bool) {
+	if len(srv.TrustedProxies) == 0 || !srv.isTrustedProxy(r.RemoteAddr) {
+		return acl.User{}, false
+	}
+
+	name := strings.TrimSpace(r.Header.Get(srv.ProxyHeaders.User))
+	if name == "" {
+		return acl.User{}, false
+	}
+
+	user := acl.User{Name: name}
+	for _, group := range strings.Split(r.Header.Get(srv.ProxyHeaders.Groups), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			user.Groups = append(user.Groups, group)
+		}
+	}
+	return user, true
+}
+
+// isTrustedProxy reports whether the address is in one of the networks of the
+// trusted proxies
+func (srv *HTTPServer) isTrustedProxy(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range srv.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses CIDRs, like 10.0.0.0/8, and single addresses
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: cidr}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// sessionUser returns the user of the OpenID Connect session of the request
+func (srv *HTTPServer) sessionUser(r *http.Request) (acl.User, bool) {
+	var s session
+	if !srv.readCookie(r, sessionCookie, &s) || s.User == "" {
+		return acl.User{}, false
+	}
+	return acl.User{Name: s.User, Groups: s.Groups}, true
+}
+
+// handleLogin sends the user to the provider to sign in
+func (srv *HTTPServer) handleLogin() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := signIn{
+			State:    oidc.RandomString(),
+			Nonce:    oidc.RandomString(),
+			Verifier: oidc.RandomString(),
+			Next:     srv.localURL(r.URL.Query().Get("next")),
+		}
+
+		authURL, err := srv.OIDC.AuthCodeURL(r.Context(), state.State, state.Nonce, oidc.Challenge(state.Verifier))
+		if err != nil {
+			srv.Logger.Error("Failed to start sign in", "error", err)
+			http.Error(w, "Sign in is unavailable", http.StatusBadGateway)
+			return
+		}
+
+		if err := srv.writeCookie(w, stateCookie, srv.BasePath+"/_mdex/auth/", state, stateLifetime); err != nil {
+			srv.Logger.Error("Failed to start sign in", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		http.Redirect(w, r, authURL, http.StatusFound)
+	})
+}
+
+// handleCallback signs the user in when the provider sends them back, and
+// sends them to the page they came from
+func (srv *HTTPServer) handleCallback() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var state signIn
+		if !srv.readCookie(r, stateCookie, &state) || !equalConstantTime(query.Get("state"), state.State) {
+			http.Error(w, "Sign in expired, please try again", http.StatusBadRequest)
+			return
+		}
+		srv.clearCookie(w, stateCookie, srv.BasePath+"/_mdex/auth/")
+
+		if reason := query.Get("error"); reason != "" {
+			srv.Logger.Info("Sign in was refused", "error", reason, "description", query.Get("error_description"))
+			http.Error(w, "Sign in was refused", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := srv.OIDC.Exchange(r.Context(), query.Get("code"), state.Verifier, state.Nonce)
+		if err != nil {
+			srv.Logger.Error("Sign in failed", "error", err)
+			http.Error(w, "Sign in failed", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := srv.OIDC.Username(claims)
+		if err != nil {
+			srv.Logger.Error("Sign in failed", "error", err)
+			http.Error(w, "Sign in failed", http.StatusUnauthorized)
+			return
+		}
+
+		s := session{User: user, Groups: claims.Groups}
+		if err := srv.writeCookie(w, sessionCookie, srv.BasePath+"/", s, sessionLifetime); err != nil {
+			srv.Logger.Error("Failed to create session", "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		srv.Logger.Info("Signed in", "user", s.User)
+		http.Redirect(w, r, state.Next, http.StatusFound)
+	})
+}
+
+// handleLogout ends the session. The session at the provider is left as it
+// is.
+func (srv *HTTPServer) handleLogout() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.clearCookie(w, sessionCookie, srv.BasePath+"/")
+		http.Redirect(w, r, srv.BasePath+"/", http.StatusFound)
+	})
+}
+
+// localURL returns the URL if it is a path on this site, and the home page
+// otherwise, so the sign in can't send users elsewhere
+func (srv *HTTPServer) localURL(next string) string {
+	home := srv.BasePath + "/"
+
+	// Browsers skip control characters and whitespace, and read backslashes
+	// as slashes, so /\t/evil.com is //evil.com to them
+	if strings.ContainsFunc(next, func(r rune) bool {
+		return r <= ' ' || r == 0x7f || r == '\\' || unicode.IsSpace(r)
+	}) {
+		return home
+	}
+
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil || u.Opaque != "" {
+		return home
+	}
+	if strings.HasPrefix(u.Path, "//") || !strings.HasPrefix(path.Clean(u.Path)+"/", home) {
+		return home
+	}
+	return next
+}
+
+// writeCookie sets a cookie with v, signed with the session key, that expires
+// after the lifetime
+func (srv *HTTPServer) writeCookie(w http.ResponseWriter, name, path string, v any, lifetime time.Duration) error {
+	expires := time.Now().Add(lifetime)
+
+	payload, err := json.Marshal(struct {
+		Expires int64 `json:"exp"`
+		Value   any   `json:"value"`
+	}{expires.Unix(), v})
+	if err != nil {
+		return err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    encoded + "." + srv.signCookie(name, encoded),
+		Path:     path,
+		Expires:  expires,
+		Secure:   srv.secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// readCookie decodes a cookie of writeCookie into v, and reports whether it
+// was there, correctly signed and not expired
+func (srv *HTTPServer) readCookie(r *http.Request, name string, v any) bool {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return false
+	}
+
+	encoded, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(srv.signCookie(name, encoded))) {
+		return false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+
+	var value struct {
+		Expires int64           `json:"exp"`
+		Value   json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(payload, &value); err != nil || time.Now().Unix() > value.Expires {
+		return false
+	}
+	return json.Unmarshal(value.Value, v) == nil
+}
+
+func (srv *HTTPServer) clearCookie(w http.ResponseWriter, name, path string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     path,
+		MaxAge:   -1,
+		Secure:   srv.secureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// signCookie signs the value of a cookie along with its name, so the value of
+// one cookie can't be used for another
+func (srv *HTTPServer) signCookie(name, value string) string {
+	mac := hmac.New(sha256.New, srv.sessionKey)
+	mac.Write([]byte(name + "=" + value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// newSessionKey returns the key that session cookies are signed with. Without
+// a secret the key is random, and sessions end when the server restarts.
+func newSessionKey(secret string) []byte {
+	if secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		return sum[:]
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
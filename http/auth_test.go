@@ -0,0 +1,425 @@
+package http
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/oidc/oidctest"
+)
+
+// newSite returns a static root with a guide and a security section
+func newSite(t *testing.T) string {
+	t.Helper()
+
+	staticRoot := t.TempDir()
+	if err := os.Mkdir(filepath.Join(staticRoot, "security"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"guide.html", "security/incidents.html"} {
+		if err := os.WriteFile(filepath.Join(staticRoot, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return staticRoot
+}
+
+func TestProxyAuth(t *testing.T) {
+	a, err := acl.Parse([]byte("rules:\n  - path: /security/\n    groups: [security]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewHTTPServer(
+		WithStaticRoot(newSite(t)),
+		WithACL(a),
+		WithTrustedProxies("10.0.0.0/8", "192.0.2.1"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		remoteAddr string
+		user       string
+		groups     string
+		path       string
+		status     int
+	}{
+		{"192.0.2.1:1234", "alice", "eng, security", "/security/incidents", http.StatusOK},
+		{"10.1.2.3:1234", "alice", "security", "/security/incidents", http.StatusOK},
+		{"10.1.2.3:1234", "bob", "eng", "/security/incidents", http.StatusForbidden},
+		{"10.1.2.3:1234", "bob", "eng", "/guide", http.StatusOK},
+		{"10.1.2.3:1234", "", "security", "/guide", http.StatusUnauthorized},
+		// The headers of other clients are ignored
+		{"198.51.100.7:1234", "alice", "security", "/guide", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.RemoteAddr = tt.remoteAddr
+		req.Header.Set("X-Forwarded-User", tt.user)
+		req.Header.Set("X-Forwarded-Groups", tt.groups)
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("Expected status %d for %s from %s on '%s', but got %d", tt.status, tt.user, tt.remoteAddr, tt.path, rr.Code)
+		}
+	}
+}
+
+func TestProxyAuth_Headers(t *testing.T) {
+	srv, err := NewHTTPServer(
+		WithStaticRoot(newSite(t)),
+		WithTrustedProxies("192.0.2.0/24"),
+		WithProxyHeaders("Remote-User", "Remote-Groups"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/guide", nil)
+	req.Header.Set("Remote-User", "alice")
+	req.Header.Set("Remote-Groups", "eng,security")
+
+	user, ok := srv.proxyUser(req)
+	if !ok || user.Name != "alice" || strings.Join(user.Groups, " ") != "eng security" {
+		t.Errorf("Expected alice in eng and security, but got %+v", user)
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := parseNetworks([]string{"10.0.0.0/8", " 192.0.2.1 ", "", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"10.0.0.0/8", "192.0.2.1/32", "::1/128"}
+	if len(networks) != len(expected) {
+		t.Fatalf("Expected %d networks, but got %d", len(expected), len(networks))
+	}
+	for i, network := range networks {
+		if network.String() != expected[i] {
+			t.Errorf("Expected network %s, but got %s", expected[i], network)
+		}
+	}
+
+	for _, cidr := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err := parseNetworks([]string{cidr}); err == nil {
+			t.Errorf("Expected an error for '%s', but got none", cidr)
+		}
+	}
+}
+
+// client is a browser for the tests, that keeps the cookies of the server and
+// leaves redirects to the test
+type client struct {
+	t       *testing.T
+	srv     *HTTPServer
+	cookies map[string]*http.Cookie
+}
+
+// get requests the path from the server, or from the provider when it is an
+// absolute URL
+func (c *client) get(target string) *http.Response {
+	c.t.Helper()
+
+	if strings.HasPrefix(target, "http") && !strings.HasPrefix(target, "https://docs.example.com/") {
+		noRedirects := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		resp, err := noRedirects.Get(target)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	req := httptest.NewRequest("GET", target, nil)
+	for _, cookie := range c.cookies {
+		req.AddCookie(cookie)
+	}
+	rr := httptest.NewRecorder()
+	c.srv.Server.Handler.ServeHTTP(rr, req)
+
+	resp := rr.Result()
+	for _, cookie := range resp.Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie
+		}
+	}
+	return resp
+}
+
+func newOIDCServer(t *testing.T) (*HTTPServer, *oidctest.Provider) {
+	t.Helper()
+
+	idp := oidctest.NewProvider(t, "mdex", "s3cret")
+
+	a, err := acl.Parse([]byte("rules:\n  - path: /security/\n    groups: [security]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewHTTPServer(
+		WithStaticRoot(newSite(t)),
+		WithBaseURL("https://docs.example.com/docs/"),
+		WithACL(a),
+		WithOIDC(idp.Issuer(), "mdex", "s3cret", ""),
+		WithSessionSecret("session secret"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, idp
+}
+
+func TestOIDC(t *testing.T) {
+	srv, idp := newOIDCServer(t)
+	idp.SignIn("alice", "security")
+
+	c := &client{t: t, srv: srv, cookies: map[string]*http.Cookie{}}
+
+	// Pages redirect to the sign in, and back once signed in
+	resp := c.get("/docs/security/incidents")
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusFound || !strings.HasPrefix(location, "/docs/_mdex/auth/login?") {
+		t.Fatalf("Expected a redirect to the sign in, but got %d to '%s'", resp.StatusCode, location)
+	}
+
+	for range 3 {
+		resp = c.get(resp.Header.Get("Location"))
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("Expected a redirect, but got %s", resp.Status)
+		}
+	}
+
+	location = resp.Header.Get("Location")
+	if location != "/docs/security/incidents" {
+		t.Fatalf("Expected a redirect back to the page, but got '%s'", location)
+	}
+
+	session := c.cookies[sessionCookie]
+	if session == nil || !session.Secure || !session.HttpOnly || session.Path != "/docs/" {
+		t.Fatalf("Expected a secure session cookie for /docs/, but got %+v", session)
+	}
+	if _, ok := c.cookies[stateCookie]; ok {
+		t.Errorf("Expected the state cookie to be cleared after signing in")
+	}
+
+	resp = c.get(location)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the page once signed in, but got %s", resp.Status)
+	}
+
+	// Signing out ends the session
+	c.get("/docs/_mdex/auth/logout")
+	if _, ok := c.cookies[sessionCookie]; ok {
+		t.Errorf("Expected the session cookie to be cleared after signing out")
+	}
+	if resp = c.get("/docs/guide"); resp.StatusCode != http.StatusFound {
+		t.Errorf("Expected a redirect to the sign in after signing out, but got %s", resp.Status)
+	}
+}
+
+func TestOIDC_Groups(t *testing.T) {
+	srv, idp := newOIDCServer(t)
+	idp.SignIn("bob", "eng")
+
+	c := &client{t: t, srv: srv, cookies: map[string]*http.Cookie{}}
+	resp := c.get("/docs/_mdex/auth/login?next=" + url.QueryEscape("/docs/security/incidents"))
+	for resp.StatusCode == http.StatusFound && !strings.HasPrefix(resp.Header.Get("Location"), "/docs/security") {
+		resp = c.get(resp.Header.Get("Location"))
+	}
+
+	if resp = c.get("/docs/security/incidents"); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected bob to be forbidden from the security section, but got %s", resp.Status)
+	}
+	if resp = c.get("/docs/guide"); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected bob to read the guide, but got %s", resp.Status)
+	}
+}
+
+func TestOIDC_Next(t *testing.T) {
+	for _, next := range []string{"/docs/%09/evil.example.com", "/%0a/evil.example.com", "//evil.example.com"} {
+		srv, idp := newOIDCServer(t)
+		idp.SignIn("alice", "security")
+
+		c := &client{t: t, srv: srv, cookies: map[string]*http.Cookie{}}
+		// The provider and then the callback
+		resp := c.get("/docs/_mdex/auth/login?next=" + next)
+		for range 2 {
+			resp = c.get(resp.Header.Get("Location"))
+		}
+
+		if location := resp.Header.Get("Location"); location != "/docs/" {
+			t.Errorf("Expected a redirect to the home page for '%s', but got '%s'", next, location)
+		}
+	}
+}
+
+func TestOIDC_UsernameClaim(t *testing.T) {
+	tests := []struct {
+		claim    string
+		verified bool
+		status   int
+	}{
+		// The subject, not the name the user picked at the provider
+		{"", true, http.StatusForbidden},
+		{"email", true, http.StatusOK},
+		{"email", false, http.StatusUnauthorized},
+		{"preferred_username", false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		idp := oidctest.NewProvider(t, "mdex", "s3cret")
+		idp.SignIn("alice")
+		idp.Claims = map[string]any{"email": "alice@example.com", "email_verified": tt.verified}
+
+		// Only alice may read the guide
+		a, err := acl.Parse([]byte("rules:\n  - path: /guide\n    users: [alice, alice@example.com]\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv, err := NewHTTPServer(
+			WithStaticRoot(newSite(t)),
+			WithBaseURL("https://docs.example.com/docs/"),
+			WithACL(a),
+			WithOIDC(idp.Issuer(), "mdex", "s3cret", ""),
+			WithOIDCUsernameClaim(tt.claim),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The provider, the callback and then the guide
+		c := &client{t: t, srv: srv, cookies: map[string]*http.Cookie{}}
+		resp := c.get("/docs/_mdex/auth/login?next=/docs/guide")
+		for range 2 {
+			resp = c.get(resp.Header.Get("Location"))
+		}
+		if resp.StatusCode == http.StatusFound {
+			resp = c.get(resp.Header.Get("Location"))
+		}
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("Expected status %d for %s with a verified address: %v, but got %s", tt.status, tt.claim, tt.verified, resp.Status)
+		}
+	}
+}
+
+func TestOIDC_TamperedSession(t *testing.T) {
+	srv, _ := newOIDCServer(t)
+
+	rr := httptest.NewRecorder()
+	if err := srv.writeCookie(rr, sessionCookie, "/docs/", session{User: "bob"}, sessionLifetime); err != nil {
+		t.Fatal(err)
+	}
+	cookie := rr.Result().Cookies()[0]
+
+	// Sign in as eve with the signature of bob
+	encoded, signature, _ := strings.Cut(cookie.Value, ".")
+	decoded, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(bytes.Replace(decoded, []byte(`"bob"`), []byte(`"eve"`), 1))
+
+	tests := map[string]string{
+		"valid":     cookie.Value,
+		"tampered":  payload + "." + signature,
+		"unsigned":  encoded,
+		"malformed": "alice",
+	}
+
+	for name, value := range tests {
+		req := httptest.NewRequest("GET", "/guide", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: value})
+
+		_, ok := srv.sessionUser(req)
+		if ok != (name == "valid") {
+			t.Errorf("Expected the %s session to be accepted: %v, but got %v", name, name == "valid", ok)
+		}
+	}
+
+	// A session can't be used as the state of a sign in
+	req := httptest.NewRequest("GET", "/docs/_mdex/auth/callback?state=x&code=y", nil)
+	req.AddCookie(&http.Cookie{Name: stateCookie, Value: cookie.Value})
+	rr = httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a session as state, but got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	tests := []struct {
+		basePath string
+		// next is escaped like in the query of the sign in
+		next     string
+		expected string
+	}{
+		{"/docs", "/docs/guide?q=1", "/docs/guide?q=1"},
+		{"/docs", "/docs/", "/docs/"},
+		{"/docs", "/docs", "/docs"},
+		{"/docs", "", "/docs/"},
+		{"/docs", "/other", "/docs/"},
+		{"/docs", "/docsx/guide", "/docs/"},
+		{"/docs", "/docs/../other", "/docs/"},
+		{"/docs", "//evil.example.com/docs/", "/docs/"},
+		{"/docs", "https://evil.example.com", "/docs/"},
+		{"/docs", `/docs/\evil.example.com`, "/docs/"},
+		{"/docs", "/docs/%09/evil.example.com", "/docs/"},
+		{"", "/guide", "/guide"},
+		{"", "//evil.example.com", "/"},
+		{"", "/%09/evil.example.com", "/"},
+		{"", "/%0a/evil.example.com", "/"},
+		{"", "%0a//evil.example.com", "/"},
+		{"", "/%e2%80%a8/evil.example.com", "/"},
+		{"", "https:evil.example.com", "/"},
+		{"", "/%5c/evil.example.com", "/"},
+	}
+
+	for _, tt := range tests {
+		next, err := url.QueryUnescape(tt.next)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		srv := &HTTPServer{BasePath: tt.basePath}
+		if got := srv.localURL(next); got != tt.expected {
+			t.Errorf("Expected '%s' for '%s' under '%s', but got '%s'", tt.expected, tt.next, tt.basePath, got)
+		}
+	}
+}
+
+func TestNewHTTPServer_OIDCErrors(t *testing.T) {
+	tests := []struct {
+		opts []Option
+		err  string
+	}{
+		{[]Option{WithOIDC("https://idp.example.com", "", "", "")}, "client ID"},
+		{[]Option{WithOIDC("https://idp.example.com", "mdex", "", "")}, "redirect URL"},
+		{[]Option{WithOIDC("https://idp.example.com", "mdex", "", ""), WithBaseURL("/docs")}, "redirect URL"},
+		{[]Option{WithTrustedProxies("proxy.local")}, "trusted proxy"},
+		{[]Option{WithOIDC("https://idp.example.com", "mdex", "", "https://docs.example.com/cb"), WithOIDCUsernameClaim("name")}, "username claim"},
+	}
+
+	for _, tt := range tests {
+		_, err := NewHTTPServer(append([]Option{WithStaticRoot(t.TempDir())}, tt.opts...)...)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Expected an error about %s, but got %v", tt.err, err)
+		}
+	}
+}
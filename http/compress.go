@@ -0,0 +1,221 @@
+package http
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the size below which responses aren't compressed, since
+// gzip saves too little on them
+const minCompressSize = 1024
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// compressResponseWriter compresses the response with gzip, when its content
+// type compresses well and it isn't compressed already
+type compressResponseWriter struct {
+	http.ResponseWriter
+	// acceptsGzip is whether the client accepts gzip
+	acceptsGzip bool
+	wroteHeader bool
+	gz          *gzip.Writer
+}
+
+func (cw *compressResponseWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		cw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") == "" && isCompressible(h.Get("Content-Type")) {
+		h.Add("Vary", "Accept-Encoding")
+
+		// Partial and small responses are sent as they are
+		size, err := strconv.Atoi(h.Get("Content-Length"))
+		small := err == nil && size < minCompressSize
+		if cw.acceptsGzip && code == http.StatusOK && h.Get("Content-Range") == "" && !small {
+			h.Del("Content-Length")
+			h.Del("Accept-Ranges")
+			h.Set("Content-Encoding", "gzip")
+			// The tag of the uncompressed response doesn't match these bytes
+			if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				h.Set("ETag", "W/"+etag)
+			}
+
+			cw.gz = gzipWriters.Get().(*gzip.Writer)
+			cw.gz.Reset(cw.ResponseWriter)
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		// Like net/http, so the content type is known before deciding
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.gz != nil {
+		return cw.gz.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been compressed so far, for streamed responses
+func (cw *compressResponseWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.gz != nil {
+		cw.gz.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close ends the compressed stream, if the response was compressed
+func (cw *compressResponseWriter) close() {
+	if cw.gz == nil {
+		return
+	}
+	cw.gz.Close()
+	cw.gz.Reset(nil)
+	gzipWriters.Put(cw.gz)
+	cw.gz = nil
+}
+
+// compressMiddleware compresses responses with gzip for the clients that
+// accept it
+func (srv *HTTPServer) compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cw := &compressResponseWriter{
+			ResponseWriter: w,
+			acceptsGzip:    acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip"),
+		}
+		defer cw.close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header accepts the
+// encoding, by name or with *, and without q=0
+func acceptsEncoding(header, encoding string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.TrimSpace(name)
+		if !strings.EqualFold(name, encoding) && name != "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || !strings.EqualFold(key, "q") {
+				continue
+			}
+
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimSpace(value), 64); err != nil {
+				q = 0
+			}
+		}
+
+		// The encoding itself takes precedence over *
+		if strings.EqualFold(name, encoding) {
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
+
+// isCompressible reports whether responses of the content type compress
+// well. Event streams aren't compressed, so events aren't held back.
+func isCompressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	switch {
+	case mediaType == "text/event-stream":
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// isPrecompressed reports whether the file is the .gz sibling of another file,
+// as opposed to a compressed file of its own, like archive.tar.gz
+func isPrecompressed(path string) bool {
+	original, ok := strings.CutSuffix(path, ".gz")
+	if !ok {
+		return false
+	}
+	info, err := os.Stat(original)
+	return err == nil && !info.IsDir()
+}
+
+// servePrecompressed serves the .gz sibling of the file, as written by
+// generate --precompress, and reports whether it did. Siblings older than the
+// file are ignored.
+func (srv *HTTPServer) servePrecompressed(w http.ResponseWriter, r *http.Request, path string) bool {
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if !isCompressible(contentType) || !acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+		return false
+	}
+
+	// http.ServeFile redirects these to their directory
+	if strings.HasSuffix(r.URL.Path, "/index.html") {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	gzInfo, err := os.Stat(path + ".gz")
+	if err != nil || gzInfo.IsDir() || gzInfo.ModTime().Before(info.ModTime()) {
+		return false
+	}
+
+	f, err := os.Open(path + ".gz")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Add("Vary", "Accept-Encoding")
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
+	return true
+}
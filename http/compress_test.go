@@ -0,0 +1,246 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gunzip returns the decompressed body of a response
+func gunzip(t *testing.T, body []byte) string {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	page := strings.Repeat("<p>Setup</p>\n", 200)
+
+	staticRoot := t.TempDir()
+	files := map[string]string{
+		"guide.html": page,
+		"small.html": "<p>Small</p>",
+		"logo.png":   strings.Repeat("\x89PNG", 500),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(staticRoot, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv, err := NewHTTPServer(WithStaticRoot(staticRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		compressed     bool
+		vary           bool
+	}{
+		{"/guide", "gzip, deflate, br", true, true},
+		{"/guide", "br;q=1.0, gzip;q=0.5", true, true},
+		{"/guide", "*", true, true},
+		{"/guide", "", false, true},
+		{"/guide", "gzip;q=0", false, true},
+		{"/guide", "*, gzip;q=0", false, true},
+		{"/small", "gzip", false, true},
+		{"/logo.png", "gzip", false, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, req)
+
+		encoding := rr.Header().Get("Content-Encoding")
+		if (encoding == "gzip") != tt.compressed {
+			t.Errorf("Expected %s with '%s' to be compressed: %v, but got Content-Encoding '%s'", tt.path, tt.acceptEncoding, tt.compressed, encoding)
+			continue
+		}
+		if vary := rr.Header().Get("Vary") == "Accept-Encoding"; vary != tt.vary {
+			t.Errorf("Expected %s to vary by Accept-Encoding: %v, but got '%s'", tt.path, tt.vary, rr.Header().Get("Vary"))
+		}
+
+		if tt.compressed {
+			if rr.Header().Get("Content-Length") != "" {
+				t.Errorf("Expected no Content-Length for compressed %s, but got %s", tt.path, rr.Header().Get("Content-Length"))
+			}
+			if body := gunzip(t, rr.Body.Bytes()); body != page {
+				t.Errorf("Expected the decompressed page for %s, but got '%s'", tt.path, body)
+			}
+		}
+	}
+}
+
+func TestCompressMiddleware_Static(t *testing.T) {
+	srv, err := NewHTTPServer(WithStaticRoot(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/static/css/main.css", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(rr, req)
+
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected the stylesheet to be compressed, but got Content-Encoding '%s'", rr.Header().Get("Content-Encoding"))
+	}
+	if body := gunzip(t, rr.Body.Bytes()); !strings.Contains(body, "{") {
+		t.Errorf("Expected the decompressed stylesheet, but got '%s'", body)
+	}
+}
+
+func TestWithCompression_Disabled(t *testing.T) {
+	staticRoot := t.TempDir()
+	page := strings.Repeat("<p>Setup</p>\n", 200)
+	if err := os.WriteFile(filepath.Join(staticRoot, "guide.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewHTTPServer(WithStaticRoot(staticRoot), WithCompression(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/guide", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(rr, req)
+
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.String() != page {
+		t.Errorf("Expected the page as it is, but got Content-Encoding '%s'", rr.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestHandleStaticRoute_Precompressed(t *testing.T) {
+	staticRoot := t.TempDir()
+
+	write := func(name, content string, modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(staticRoot, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("precompressed"))
+	zw.Close()
+
+	now := time.Now()
+	write("guide.html", "<p>Guide</p>", now.Add(-time.Hour))
+	write("guide.html.gz", buf.String(), now)
+	// A sibling older than its file is out of date
+	write("stale.html", "<p>Stale</p>", now)
+	write("stale.html.gz", buf.String(), now.Add(-time.Hour))
+	// A compressed file of its own is served as it is
+	write("archive.tar.gz", "archive", now)
+
+	srv, err := NewHTTPServer(WithStaticRoot(staticRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"/guide", "gzip", "gzip", "precompressed"},
+		{"/guide.html", "gzip", "gzip", "precompressed"},
+		{"/guide", "", "", "<p>Guide</p>"},
+		{"/stale", "gzip", "", "<p>Stale</p>"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("Expected status %d for %s, but got %d", http.StatusOK, tt.path, rr.Code)
+			continue
+		}
+		if encoding := rr.Header().Get("Content-Encoding"); encoding != tt.encoding {
+			t.Errorf("Expected Content-Encoding '%s' for %s, but got '%s'", tt.encoding, tt.path, encoding)
+			continue
+		}
+		if contentType := rr.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/html") {
+			t.Errorf("Expected an HTML content type for %s, but got '%s'", tt.path, contentType)
+		}
+		if vary := rr.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+			t.Errorf("Expected to vary by Accept-Encoding once for %s, but got %v", tt.path, vary)
+		}
+
+		body := rr.Body.String()
+		if tt.encoding == "gzip" {
+			body = gunzip(t, rr.Body.Bytes())
+		}
+		if body != tt.body {
+			t.Errorf("Expected body '%s' for %s, but got '%s'", tt.body, tt.path, body)
+		}
+	}
+
+	// Siblings are only served in place of their file
+	statuses := map[string]int{
+		"/guide.html.gz":  http.StatusNotFound,
+		"/stale.html.gz":  http.StatusNotFound,
+		"/archive.tar.gz": http.StatusOK,
+	}
+	for path, status := range statuses {
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != status {
+			t.Errorf("Expected status %d for %s, but got %d", status, path, rr.Code)
+		}
+	}
+}
+
+func TestAcceptsEncoding(t *testing.T) {
+	tests := map[string]bool{
+		"gzip":                true,
+		"GZIP":                true,
+		"deflate, gzip;q=0.8": true,
+		"gzip; q=0.001":       true,
+		"*":                   true,
+		"":                    false,
+		"identity":            false,
+		"gzip;q=0":            false,
+		"gzip;q=0.0":          false,
+		"gzip;q=invalid":      false,
+		"gzip;q=0, *":         false,
+		"*;q=0":               false,
+		"x-gzip":              false,
+	}
+
+	for header, expected := range tests {
+		if got := acceptsEncoding(header, "gzip"); got != expected {
+			t.Errorf("Expected %v for '%s', but got %v", expected, header, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package http
+
+import "sync"
+
+// ReloadEvent tells connected browsers that the site was regenerated
+type ReloadEvent struct {
+	// Routes are the routes of the pages that were rendered again
+	Routes []string `json:"routes"`
+	// Structural is set when the navigation changed, in which case the
+	// pages need a full reload instead of only swapping their content
+	Structural bool `json:"structural"`
+	// All is set when every page needs a full reload, for example because
+	// an asset changed that any of them can refer to
+	All bool `json:"all,omitempty"`
+}
+
+// BroadcastReload sends a reload event to all connected browsers
+func (srv *HTTPServer) BroadcastReload(event ReloadEvent) {
+	srv.reloads.publish(event)
+}
+
+// broadcaster fans out reload events to the subscribed event streams
+type broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan ReloadEvent]struct{}
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{
+		subscribers: make(map[chan ReloadEvent]struct{}),
+	}
+}
+
+func (b *broadcaster) subscribe() chan ReloadEvent {
+	ch := make(chan ReloadEvent, 8)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch
+}
+
+func (b *broadcaster) unsubscribe(ch chan ReloadEvent) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+}
+
+func (b *broadcaster) publish(event ReloadEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Don't block on slow clients, they will catch up with
+			// the next event
+		}
+	}
+}
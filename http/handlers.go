@@ -1,18 +1,34 @@
 package http
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/jpbruinsslot/mdex/http/assets"
 )
 
-func (srv *HTTPServer) handleStatic() http.HandlerFunc {
+// handleStatic serves the stylesheets, scripts and icons of the templates, and
+// leaves the other files in /static/ to the site
+func (srv *HTTPServer) handleStatic(site http.Handler) http.HandlerFunc {
+	var staticHandler http.Handler = http.StripPrefix("/static/", http.FileServer(http.FS(assets.FS)))
+	if srv.Compress {
+		staticHandler = srv.compressMiddleware(staticHandler)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		staticHandler := http.FileServer(http.FS(assets.FS))
-		http.StripPrefix("/static/", staticHandler).ServeHTTP(w, r)
+		name := strings.TrimPrefix(r.URL.Path, "/static/")
+		if info, err := fs.Stat(assets.FS, name); err != nil || info.IsDir() {
+			site.ServeHTTP(w, r)
+			return
+		}
+		staticHandler.ServeHTTP(w, r)
 	})
 }
 
@@ -20,6 +36,12 @@ func (srv *HTTPServer) handleStaticRoute() http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		path := r.URL.Path
 
+		// Hidden files, like the build manifest, are never served
+		if isHiddenPath(path) {
+			http.NotFound(w, r)
+			return
+		}
+
 		// When it has a trailing slash try the index.html page
 		if strings.HasSuffix(path, "/") {
 			path = strings.TrimSuffix(path, "/") + "/index"
@@ -28,11 +50,6 @@ func (srv *HTTPServer) handleStaticRoute() http.HandlerFunc {
 		// Resolve the full path to the requested file
 		requestedPath := filepath.Join(srv.StaticRoot, filepath.Clean(path))
 
-		// If the path does not end with .html, add it
-		if !strings.HasSuffix(requestedPath, ".html") {
-			requestedPath += ".html"
-		}
-
 		// Get absolute paths
 		absStaticRoot, err := filepath.Abs(srv.StaticRoot)
 		if err != nil {
@@ -52,6 +69,24 @@ func (srv *HTTPServer) handleStaticRoute() http.HandlerFunc {
 			return
 		}
 
+		// Precompressed siblings are only served in place of their file
+		if isPrecompressed(absRequestedPath) {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Serve existing files, like images, as they are. The content type
+		// is derived from their extension.
+		if info, err := os.Stat(absRequestedPath); err == nil && !info.IsDir() {
+			srv.serveFile(w, r, absRequestedPath)
+			return
+		}
+
+		// Otherwise it's a page, so add .html when it isn't there yet
+		if !strings.HasSuffix(absRequestedPath, ".html") {
+			absRequestedPath += ".html"
+		}
+
 		// Check if file exists
 		if _, err := os.Stat(absRequestedPath); os.IsNotExist(err) {
 			http.NotFound(w, r)
@@ -59,6 +94,77 @@ func (srv *HTTPServer) handleStaticRoute() http.HandlerFunc {
 		}
 
 		// Serve the file
-		http.ServeFile(w, r, absRequestedPath)
+		srv.serveFile(w, r, absRequestedPath)
+	})
+}
+
+// serveFile serves the file, or its precompressed sibling when there is one
+// and the client accepts it
+func (srv *HTTPServer) serveFile(w http.ResponseWriter, r *http.Request, path string) {
+	if srv.Compress && srv.servePrecompressed(w, r, path) {
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+// isHiddenPath reports whether any part of the URL path starts with a dot
+func isHiddenPath(path string) bool {
+	for _, name := range strings.Split(path, "/") {
+		if strings.HasPrefix(name, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEvents streams reload events to the browser using Server-Sent Events
+func (srv *HTTPServer) handleEvents() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+
+		// The stream outlives the write timeout of the server
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		events := srv.reloads.subscribe()
+		defer srv.reloads.unsubscribe(events)
+
+		// Have the client reconnect quickly when the server restarts
+		fmt.Fprint(w, "retry: 1000\n\n")
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(30 * time.Second)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-srv.done:
+				return
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case event := <-events:
+				data, err := json.Marshal(event)
+				if err != nil {
+					srv.Logger.Error("Failed to encode reload event", "error", err)
+					continue
+				}
+				fmt.Fprintf(w, "event: reload\ndata: %s\n\n", data)
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
 	})
 }
@@ -1,10 +1,12 @@
 package http
 
 import (
+	"bufio"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -26,7 +28,7 @@ func TestHandleStatic(t *testing.T) {
 	}
 
 	rr := httptest.NewRecorder()
-	handler := srv.handleStatic()
+	handler := srv.handleStatic(http.NotFoundHandler())
 	handler.ServeHTTP(rr, req)
 
 	if status := rr.Code; status != http.StatusOK {
@@ -81,3 +83,171 @@ func TestHandleStaticRoute(t *testing.T) {
 			status, http.StatusNotFound)
 	}
 }
+
+func TestHandleEvents(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv, err := NewHTTPServer(WithStaticRoot(tempDir), WithLiveReload(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testServer := httptest.NewServer(srv.Router)
+	defer testServer.Close()
+
+	resp, err := http.Get(testServer.URL + "/_mdex/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, but got %d", http.StatusOK, resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Errorf("Expected content type 'text/event-stream', but got '%s'", contentType)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+
+	// The retry field is sent once the client is subscribed
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("Expected retry field, but got '%s'", line)
+	}
+	if _, err := reader.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.BroadcastReload(ReloadEvent{Routes: []string{"/docs/intro"}, Structural: true})
+
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+
+	if lines[0] != "event: reload" {
+		t.Errorf("Expected 'event: reload', but got '%s'", lines[0])
+	}
+	expected := `data: {"routes":["/docs/intro"],"structural":true}`
+	if lines[1] != expected {
+		t.Errorf("Expected '%s', but got '%s'", expected, lines[1])
+	}
+}
+
+func TestHandleEventsDisabled(t *testing.T) {
+	tempDir := t.TempDir()
+
+	srv, err := NewHTTPServer(WithStaticRoot(tempDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/_mdex/events", nil)
+	rr := httptest.NewRecorder()
+	srv.Router.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusNotFound)
+	}
+}
+
+func TestHandleStaticRouteServesFiles(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"guide.html":          "<p>Guide</p>",
+		"img/arch.png":        "\x89PNG\r\n\x1a\n",
+		"manual.pdf":          "%PDF-1.4",
+		".mdex-manifest.json": "{}",
+	}
+	for name, content := range files {
+		path := filepath.Join(tempDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv, err := NewHTTPServer(WithStaticRoot(tempDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := srv.handleStaticRoute()
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+	}{
+		{"/img/arch.png", http.StatusOK, "image/png"},
+		{"/manual.pdf", http.StatusOK, "application/pdf"},
+		{"/guide", http.StatusOK, "text/html; charset=utf-8"},
+		{"/guide.html", http.StatusOK, "text/html; charset=utf-8"},
+		{"/.mdex-manifest.json", http.StatusNotFound, ""},
+		{"/img/missing.png", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("Expected status %d for '%s', but got %d", tt.status, tt.path, rr.Code)
+			continue
+		}
+
+		if tt.contentType != "" && rr.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("Expected content type '%s' for '%s', but got '%s'", tt.contentType, tt.path, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestHandleStatic_SiteFiles(t *testing.T) {
+	staticRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(staticRoot, "static", "img"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(staticRoot, "static", "img", "logo.png"), []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newACLServer(t, WithStaticRoot(staticRoot))
+
+	tests := []struct {
+		user   string
+		path   string
+		status int
+	}{
+		// The files of the templates don't require a sign in
+		{"", "/static/css/main.css", http.StatusOK},
+		// The files of the site do, like the rest of the site
+		{"", "/static/img/logo.png", http.StatusUnauthorized},
+		{"alice", "/static/img/logo.png", http.StatusOK},
+		{"alice", "/static/img/missing.png", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, "secret")
+		}
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("Expected status %d for %s on '%s', but got %d", tt.status, tt.user, tt.path, rr.Code)
+		}
+	}
+}
@@ -1,9 +1,18 @@
 package http
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
 	"time"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/parser"
 )
 
 type Middleware func(http.Handler) http.Handler
@@ -32,6 +41,12 @@ func (lrw *loggingResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap allows http.ResponseController to reach the underlying writer, for
+// example to flush streamed responses
+func (lrw *loggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
+
 func (srv *HTTPServer) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -56,14 +71,118 @@ func (srv *HTTPServer) loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func (srv *HTTPServer) basicAuthMiddleware(next http.Handler) http.Handler {
+// authMiddleware requires a signed-in user, who is identified by the headers
+// of a trusted proxy, an OpenID Connect session or basic auth, in that order
+func (srv *HTTPServer) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		username, password, ok := r.BasicAuth()
-		if !ok || username != srv.BasicAuth.Username || password != srv.BasicAuth.Password {
-			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		user, ok := srv.proxyUser(r)
+		if !ok && srv.OIDC != nil {
+			user, ok = srv.sessionUser(r)
+		}
+		if !ok && srv.hasBasicAuth() {
+			if username, password, found := r.BasicAuth(); found && srv.authenticate(username, password) {
+				user, ok = acl.User{Name: username}, true
+			}
+		}
+
+		if !ok {
+			srv.challenge(w, r)
+			return
+		}
+		next.ServeHTTP(w, withUser(r, user))
+	})
+}
+
+// challenge asks the user to sign in. Pages are redirected to the sign in
+// of the OpenID Connect provider, if there is one.
+func (srv *HTTPServer) challenge(w http.ResponseWriter, r *http.Request) {
+	if srv.OIDC != nil && r.Method == http.MethodGet && !strings.HasPrefix(r.URL.Path, "/_mdex/") {
+		next := srv.BasePath + r.URL.RequestURI()
+		http.Redirect(w, r, srv.BasePath+loginPath+"?next="+url.QueryEscape(next), http.StatusFound)
+		return
+	}
+
+	if srv.hasBasicAuth() {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+	}
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// hasBasicAuth reports whether users can sign in with basic auth
+func (srv *HTTPServer) hasBasicAuth() bool {
+	return srv.BasicAuth.Username != "" || srv.Htpasswd != nil
+}
+
+// authenticate reports whether the credentials match the single configured
+// user, or a user in the htpasswd file
+func (srv *HTTPServer) authenticate(username, password string) bool {
+	if srv.BasicAuth.Username != "" {
+		// Both are compared, so the time taken doesn't tell which is wrong
+		userOK := equalConstantTime(username, srv.BasicAuth.Username)
+		passwordOK := equalConstantTime(password, srv.BasicAuth.Password)
+		if userOK && passwordOK {
+			return true
+		}
+	}
+
+	if srv.Htpasswd != nil {
+		if changed, err := srv.Htpasswd.Reload(); err != nil {
+			srv.Logger.Error("Failed to reload htpasswd file, using the previous users", "error", err)
+		} else if changed {
+			srv.Logger.Info("Reloaded htpasswd file", "file", srv.Htpasswd.Path)
+		}
+		return srv.Htpasswd.Verify(username, password)
+	}
+
+	return false
+}
+
+// equalConstantTime compares the digests of a and b, so the time taken
+// depends on neither their contents nor their lengths
+func equalConstantTime(a, b string) bool {
+	digestA := sha256.Sum256([]byte(a))
+	digestB := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(digestA[:], digestB[:]) == 1
+}
+
+// userKey is the context key of the signed-in user
+type userKey struct{}
+
+// withUser returns the request with the signed-in user in its context
+func withUser(r *http.Request, user acl.User) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userKey{}, user))
+}
+
+// UserFromContext returns the user that signed in to make the request, as set
+// by the authentication middleware
+func UserFromContext(ctx context.Context) (acl.User, bool) {
+	user, ok := ctx.Value(userKey{}).(acl.User)
+	return user, ok
+}
+
+// aclMiddleware denies requests for the routes the user can't access. It runs
+// after the authentication middleware, which sets the user.
+func (srv *HTTPServer) aclMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The search index of the browser holds every page, the search
+		// endpoint leaves out the ones the user can't access instead
+		if isSearchIndexPath(r.URL.Path) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		user, _ := UserFromContext(r.Context())
+		if !srv.ACL.Allowed(r.URL.Path, user) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// isSearchIndexPath reports whether the URL path is in the search index of
+// the browser
+func isSearchIndexPath(urlPath string) bool {
+	first, _, _ := strings.Cut(strings.TrimPrefix(path.Clean("/"+urlPath), "/"), "/")
+	return first == parser.SearchDir
+}
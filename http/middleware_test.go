@@ -0,0 +1,171 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpbruinsslot/mdex/acl"
+)
+
+func TestBasicAuthMiddleware(t *testing.T) {
+	htpasswdFile := filepath.Join(t.TempDir(), "htpasswd")
+	// alice:secret with bcrypt, bob:secret with SHA-256 crypt
+	alice := "alice:$2y$04$abcdefghijklmnopqrstuu2r9OfJnfCsdneAXAGHnS4UpFFP8WIrW\n"
+	bob := "bob:$5$saltsalt$0IyaXrmV7.sGNS6tirgqHLqX/G.FBvgkYA.lpPdS5sA\n"
+	if err := os.WriteFile(htpasswdFile, []byte(alice+bob), 0600); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(htpasswdFile, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewHTTPServer(
+		WithStaticRoot(t.TempDir()),
+		WithBasicAuth("admin", "pass"),
+		WithHtpasswd(htpasswdFile),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), srv.authMiddleware)
+
+	request := func(username, password string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	tests := []struct {
+		username string
+		password string
+		status   int
+	}{
+		{"admin", "pass", http.StatusOK},
+		{"alice", "secret", http.StatusOK},
+		{"bob", "secret", http.StatusOK},
+		{"admin", "secret", http.StatusUnauthorized},
+		{"alice", "pass", http.StatusUnauthorized},
+		{"carol", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		if status := request(tt.username, tt.password); status != tt.status {
+			t.Errorf("Expected status %d for %s:%s, but got %d", tt.status, tt.username, tt.password, status)
+		}
+	}
+
+	// Users removed from the file can't sign in anymore
+	if err := os.WriteFile(htpasswdFile, []byte(alice), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if status := request("bob", "secret"); status != http.StatusUnauthorized {
+		t.Errorf("Expected a removed user to be unauthorized, but got %d", status)
+	}
+	if status := request("alice", "secret"); status != http.StatusOK {
+		t.Errorf("Expected status %d for alice after the reload, but got %d", http.StatusOK, status)
+	}
+}
+
+func TestNewHTTPServer_InvalidHtpasswd(t *testing.T) {
+	htpasswdFile := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(htpasswdFile, []byte("alice:$apr1$salt$hash\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewHTTPServer(WithStaticRoot(t.TempDir()), WithHtpasswd(htpasswdFile)); err == nil {
+		t.Error("Expected an error for an unsupported password hash")
+	}
+}
+
+// newACLServer returns a server with alice and bob, where only alice may
+// access /security/
+func newACLServer(t *testing.T, opts ...Option) *HTTPServer {
+	t.Helper()
+
+	dir := t.TempDir()
+	htpasswdFile := filepath.Join(dir, "htpasswd")
+	users := "alice:$2y$04$abcdefghijklmnopqrstuu2r9OfJnfCsdneAXAGHnS4UpFFP8WIrW\n" +
+		"bob:$5$saltsalt$0IyaXrmV7.sGNS6tirgqHLqX/G.FBvgkYA.lpPdS5sA\n"
+	if err := os.WriteFile(htpasswdFile, []byte(users), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := acl.Parse([]byte("groups:\n  security: [alice]\nrules:\n  - path: /security/\n    groups: [security]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewHTTPServer(append([]Option{WithHtpasswd(htpasswdFile), WithACL(a)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestACLMiddleware(t *testing.T) {
+	staticRoot := t.TempDir()
+	for _, dir := range []string{"security", "_search/terms"} {
+		if err := os.MkdirAll(filepath.Join(staticRoot, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"guide.html", "security/incidents.html", "security/incidents.html.gz", "_search/terms/r.json"} {
+		if err := os.WriteFile(filepath.Join(staticRoot, file), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := newACLServer(t, WithStaticRoot(staticRoot), WithBaseURL("/docs"))
+
+	tests := []struct {
+		user   string
+		path   string
+		status int
+	}{
+		{"alice", "/docs/guide", http.StatusOK},
+		{"alice", "/docs/security/incidents", http.StatusOK},
+		{"alice", "/docs/security/incidents.html", http.StatusOK},
+		{"bob", "/docs/guide", http.StatusOK},
+		{"bob", "/docs/security/incidents", http.StatusForbidden},
+		{"bob", "/docs/security/incidents.html", http.StatusForbidden},
+		{"bob", "/docs/security/", http.StatusForbidden},
+		{"bob", "/docs/security/incidents.html.gz", http.StatusForbidden},
+		{"alice", "/docs/security/incidents.html.gz", http.StatusNotFound},
+		{"", "/docs/security/incidents", http.StatusUnauthorized},
+		// The search index of the browser holds the pages of everyone
+		{"alice", "/docs/_search/terms/r.json", http.StatusForbidden},
+		{"alice", "/docs/_search/", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.user != "" {
+			req.SetBasicAuth(tt.user, "secret")
+		}
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("Expected status %d for %s on '%s', but got %d", tt.status, tt.user, tt.path, rr.Code)
+		}
+	}
+}
+
+func TestNewHTTPServer_ACLWithoutAuth(t *testing.T) {
+	_, err := NewHTTPServer(WithStaticRoot(t.TempDir()), WithACL(&acl.ACL{}))
+	if err == nil || !strings.Contains(err.Error(), "require authentication") {
+		t.Errorf("Expected an error about authentication, but got %v", err)
+	}
+}
@@ -1,10 +1,50 @@
 package http
 
+import (
+	"time"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/oidc"
+)
+
 type Options struct {
 	Port       string
 	StaticRoot string
 	Username   string
 	Password   string
+	// HtpasswdFile holds the users that may sign in with basic auth
+	HtpasswdFile string
+	// ACL restricts sections of the site to users and groups
+	ACL *acl.ACL
+	// TrustedProxies are the networks of the reverse proxies whose identity
+	// headers are trusted, like 10.0.0.0/8
+	TrustedProxies    []string
+	ProxyUserHeader   string
+	ProxyGroupsHeader string
+	// OIDC signs users in with an OpenID Connect provider, see WithOIDC
+	OIDC oidc.Config
+	// SessionSecret signs the session cookies, so sessions survive restarts
+	SessionSecret string
+
+	LiveReload bool
+	BaseURL    string
+	Search     bool
+	Renderer   Renderer
+	// Compress compresses responses with gzip, see WithCompression
+	Compress bool
+	// ShutdownTimeout is how long in-flight requests are given to finish
+	// when the server stops
+	ShutdownTimeout time.Duration
+	// TLSCertFile and TLSKeyFile are the certificate and key for HTTPS
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSSelfSigned serves HTTPS with a self-signed certificate for
+	// development, which is kept in TLSCacheDir
+	TLSSelfSigned bool
+	TLSCacheDir   string
+	// RedirectPort is the port of a plain HTTP listener that redirects to
+	// HTTPS, or empty for none
+	RedirectPort string
 }
 
 type Option func(*Options)
@@ -27,3 +67,144 @@ func WithBasicAuth(user, pass string) Option {
 		o.Password = pass
 	}
 }
+
+// WithHtpasswd requires basic auth with one of the users in the htpasswd
+// file. The file is read again when it changes.
+func WithHtpasswd(path string) Option {
+	return func(o *Options) {
+		o.HtpasswdFile = path
+	}
+}
+
+// WithACL restricts sections of the site to the users and groups of the ACL.
+// It requires authentication, to know who the users are.
+func WithACL(a *acl.ACL) Option {
+	return func(o *Options) {
+		o.ACL = a
+	}
+}
+
+// WithTrustedProxies identifies users by the headers of the reverse proxies in
+// the networks, like 10.0.0.0/8 or a single address. The headers of other
+// clients are ignored.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(o *Options) {
+		o.TrustedProxies = cidrs
+	}
+}
+
+// WithProxyHeaders sets the headers of the trusted proxies with the name of
+// the user and a comma separated list of their groups. They are
+// X-Forwarded-User and X-Forwarded-Groups by default.
+func WithProxyHeaders(userHeader, groupsHeader string) Option {
+	return func(o *Options) {
+		o.ProxyUserHeader = userHeader
+		o.ProxyGroupsHeader = groupsHeader
+	}
+}
+
+// WithOIDC signs users in with the OpenID Connect provider at the issuer URL,
+// with the authorization code flow and PKCE. The redirect URL is optional, it
+// defaults to the callback under the base URL, which must be absolute then.
+func WithOIDC(issuer, clientID, clientSecret, redirectURL string) Option {
+	return func(o *Options) {
+		o.OIDC.Issuer = issuer
+		o.OIDC.ClientID = clientID
+		o.OIDC.ClientSecret = clientSecret
+		o.OIDC.RedirectURL = redirectURL
+	}
+}
+
+// WithOIDCUsernameClaim sets the claim of the ID tokens that users are known
+// by in the access rules, see oidc.UsernameClaims. It is sub by default.
+func WithOIDCUsernameClaim(claim string) Option {
+	return func(o *Options) {
+		o.OIDC.UsernameClaim = claim
+	}
+}
+
+// WithSessionSecret signs the session cookies of OpenID Connect with a key
+// derived from the secret. Without it a random key is used, and users have
+// to sign in again after a restart.
+func WithSessionSecret(secret string) Option {
+	return func(o *Options) {
+		o.SessionSecret = secret
+	}
+}
+
+// WithLiveReload enables the live reload events endpoint, used during
+// development to refresh open pages after the site is regenerated
+func WithLiveReload(liveReload bool) Option {
+	return func(o *Options) {
+		o.LiveReload = liveReload
+	}
+}
+
+// WithBaseURL mounts the site under the path of the base URL, like /eng/docs
+// for https://intranet/eng/docs/
+func WithBaseURL(baseURL string) Option {
+	return func(o *Options) {
+		o.BaseURL = baseURL
+	}
+}
+
+// WithSearch enables the search endpoint, which searches an index of the
+// pages in the static root that is kept in memory
+func WithSearch(search bool) Option {
+	return func(o *Options) {
+		o.Search = search
+	}
+}
+
+// WithRenderer renders the pages on request with the renderer, instead of
+// serving the files in the static root
+func WithRenderer(renderer Renderer) Option {
+	return func(o *Options) {
+		o.Renderer = renderer
+	}
+}
+
+// WithCompression compresses responses with gzip for the clients that accept
+// it, and serves the .gz files of generate --precompress instead of the files
+// next to them. It is enabled by default.
+func WithCompression(compress bool) Option {
+	return func(o *Options) {
+		o.Compress = compress
+	}
+}
+
+// WithShutdownTimeout sets how long in-flight requests are given to finish
+// when the server stops
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ShutdownTimeout = timeout
+	}
+}
+
+// WithTLS serves HTTPS with the certificate and key files. The files are
+// loaded again when they change, so rotated certificates are used without a
+// restart.
+func WithTLS(certFile, keyFile string) Option {
+	return func(o *Options) {
+		o.TLSCertFile = certFile
+		o.TLSKeyFile = keyFile
+	}
+}
+
+// WithSelfSignedTLS serves HTTPS with a self-signed certificate for
+// localhost, for development. The certificate is kept in cacheDir, or in the
+// user cache directory when empty, and used until it expires.
+func WithSelfSignedTLS(cacheDir string) Option {
+	return func(o *Options) {
+		o.TLSSelfSigned = true
+		o.TLSCacheDir = cacheDir
+	}
+}
+
+// WithHTTPRedirect listens for plain HTTP on the port as well, and redirects
+// its requests to HTTPS
+func WithHTTPRedirect(port string) Option {
+	return func(o *Options) {
+		o.RedirectPort = port
+	}
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/parser"
+)
+
+// Renderer renders the pages of the site on request, see parser.Renderer
+type Renderer interface {
+	// Render returns the page at a route, or parser.ErrNotFound
+	Render(route string) ([]byte, error)
+	// Asset returns the path of the file at a route that is served as it
+	// is, like an image
+	Asset(route string) (string, bool)
+}
+
+// UserRenderer is implemented by renderers that render the site as each user
+// sees it, with the pages they can't access left out of the navigation, see
+// parser.Renderer
+type UserRenderer interface {
+	RenderFor(route string, user acl.User) ([]byte, error)
+}
+
+// handleRender serves the pages of the site, rendered on request from the
+// markdown files, and the other files next to them as they are
+func (srv *HTTPServer) handleRender() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		// Hidden files are never served
+		if isHiddenPath(path) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if file, ok := srv.Renderer.Asset(path); ok {
+			http.ServeFile(w, r, file)
+			return
+		}
+
+		var page []byte
+		var err error
+		if renderer, ok := srv.Renderer.(UserRenderer); ok {
+			user, _ := UserFromContext(r.Context())
+			page, err = renderer.RenderFor(path, user)
+		} else {
+			page, err = srv.Renderer.Render(path)
+		}
+		if errors.Is(err, parser.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			srv.Logger.Error("Failed to render page", "path", path, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write(page); err != nil {
+			srv.Logger.Error("Failed to write page", "path", path, "error", err)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/parser"
+)
+
+type testRenderer struct {
+	pages  map[string]string
+	assets map[string]string
+}
+
+func (r *testRenderer) Render(route string) ([]byte, error) {
+	page, ok := r.pages[route]
+	if !ok {
+		return nil, parser.ErrNotFound
+	}
+	return []byte(page), nil
+}
+
+func (r *testRenderer) Asset(route string) (string, bool) {
+	path, ok := r.assets[route]
+	return path, ok
+}
+
+func TestHandleRender(t *testing.T) {
+	logo := filepath.Join(t.TempDir(), "logo.txt")
+	if err := os.WriteFile(logo, []byte("logo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	renderer := &testRenderer{
+		pages:  map[string]string{"/guide/setup": "<p>Setup</p>"},
+		assets: map[string]string{"/logo.txt": logo},
+	}
+
+	// The static root isn't needed when pages are rendered on request
+	srv, err := NewHTTPServer(WithStaticRoot("does-not-exist"), WithRenderer(renderer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/guide/setup", http.StatusOK, "<p>Setup</p>"},
+		{"/logo.txt", http.StatusOK, "logo"},
+		{"/missing", http.StatusNotFound, ""},
+		{"/.mdex-manifest.json", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, httptest.NewRequest("GET", test.path, nil))
+
+		if rr.Code != test.status {
+			t.Errorf("Expected status %d for %s, but got %d", test.status, test.path, rr.Code)
+		}
+		if test.body != "" && rr.Body.String() != test.body {
+			t.Errorf("Expected body '%s' for %s, but got '%s'", test.body, test.path, rr.Body.String())
+		}
+	}
+}
+
+// userRenderer renders the pages of the site, with the name of the user
+type userRenderer struct {
+	testRenderer
+}
+
+func (r *userRenderer) RenderFor(route string, user acl.User) ([]byte, error) {
+	page, err := r.Render(route)
+	if err != nil {
+		return nil, err
+	}
+	return append(page, " for "+user.Name...), nil
+}
+
+func TestHandleRender_User(t *testing.T) {
+	renderer := &userRenderer{testRenderer{pages: map[string]string{"/guide": "Guide"}}}
+
+	srv, err := NewHTTPServer(WithRenderer(renderer), WithBasicAuth("alice", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/guide", nil)
+	req.SetBasicAuth("alice", "secret")
+	rr := httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(rr, req)
+
+	if rr.Body.String() != "Guide for alice" {
+		t.Errorf("Expected the page to be rendered for alice, but got '%s'", rr.Body.String())
+	}
+}
@@ -1,6 +1,29 @@
 package http
 
 func (srv *HTTPServer) RegisterRoutes() {
-	srv.Router.Handle("GET /static/", srv.handleStatic())
-	srv.Router.Handle("GET /", Chain(srv.handleStaticRoute(), srv.Middleware...))
+	site := Chain(srv.handleStaticRoute(), srv.Middleware...)
+	if srv.Renderer != nil {
+		site = Chain(srv.handleRender(), srv.Middleware...)
+	}
+
+	// Files in the static directory of the site are served like the rest of
+	// the site, when the templates don't have them
+	srv.Router.Handle("GET /static/", srv.handleStatic(site))
+
+	// Signing in doesn't require a signed-in user
+	if srv.OIDC != nil {
+		srv.Router.Handle("GET "+loginPath, Chain(srv.handleLogin(), srv.loggingMiddleware))
+		srv.Router.Handle("GET "+callbackPath, Chain(srv.handleCallback(), srv.loggingMiddleware))
+		srv.Router.Handle("GET "+logoutPath, Chain(srv.handleLogout(), srv.loggingMiddleware))
+	}
+
+	if srv.LiveReload {
+		srv.Router.Handle("GET /_mdex/events", Chain(srv.handleEvents(), srv.Middleware...))
+	}
+
+	if srv.Search {
+		srv.Router.Handle("GET /_mdex/search", Chain(srv.handleSearch(), srv.Middleware...))
+	}
+
+	srv.Router.Handle("GET /", site)
 }
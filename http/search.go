@@ -0,0 +1,110 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/jpbruinsslot/mdex/parser"
+	"github.com/jpbruinsslot/mdex/search"
+	"github.com/jpbruinsslot/mdex/watcher"
+)
+
+// maxSearchResults is the number of results the search endpoint returns
+const maxSearchResults = 20
+
+// searchResponse is the JSON response of the search endpoint
+type searchResponse struct {
+	Query   string          `json:"query"`
+	Total   int             `json:"total"`
+	Results []search.Result `json:"results"`
+}
+
+// RebuildSearchIndex indexes the pages in the static root, and replaces the
+// index searched by the search endpoint
+func (srv *HTTPServer) RebuildSearchIndex() error {
+	docs, err := search.ReadDir(srv.StaticRoot)
+	if err != nil {
+		return fmt.Errorf("failed to index pages: %w", err)
+	}
+
+	srv.searchIndex.Store(search.New(docs))
+	srv.Logger.Info("Search index built", "pages", len(docs))
+	return nil
+}
+
+// watchSearchIndex rebuilds the search index whenever the pages in the static
+// root change, until the context is cancelled
+func (srv *HTTPServer) watchSearchIndex(ctx context.Context) error {
+	w := watcher.New(srv.StaticRoot, watcher.WithSkip(func(path string, d fs.DirEntry) bool {
+		return strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_")
+	}), watcher.WithLogger(srv.Logger))
+
+	return w.Run(ctx, func(events []watcher.Event) {
+		for _, event := range events {
+			if strings.HasSuffix(event.Path, ".html") {
+				if err := srv.RebuildSearchIndex(); err != nil {
+					srv.Logger.Error("Failed to rebuild the search index", "error", err)
+				}
+				return
+			}
+		}
+	})
+}
+
+// handleSearch searches the pages of the site. It returns JSON when asked for
+// with format=json or the Accept header, and a results page otherwise.
+func (srv *HTTPServer) handleSearch() http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+		var response searchResponse
+		response.Query = query
+		if idx := srv.searchIndex.Load(); idx != nil {
+			// Pages the user can't access aren't found
+			var keep func(string) bool
+			if srv.ACL != nil {
+				user, _ := UserFromContext(r.Context())
+				keep = srv.ACL.Access(user).Allowed
+			}
+			response.Results, response.Total = idx.SearchFunc(query, maxSearchResults, keep)
+		}
+		if response.Results == nil {
+			response.Results = []search.Result{}
+		}
+
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(response); err != nil {
+				srv.Logger.Error("Failed to encode search results", "error", err)
+			}
+			return
+		}
+
+		title := "Search"
+		if query != "" {
+			title = "Search: " + query
+		}
+
+		data := parser.TemplateData{
+			Title:        title,
+			BasePath:     srv.BasePath,
+			LiveReload:   srv.LiveReload,
+			Search:       true,
+			ServerSearch: true,
+			SearchResults: &parser.SearchResults{
+				Query:   response.Query,
+				Total:   response.Total,
+				Results: response.Results,
+			},
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := srv.templates["search"].Execute(w, data); err != nil {
+			srv.Logger.Error("Failed to render search results", "error", err)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleSearch(t *testing.T) {
+	tempDir := t.TempDir()
+
+	page := `<html><head><title>Setup</title></head><body><article><h1>Setup</h1><p>Run make install.</p></article></body></html>`
+	if err := os.WriteFile(filepath.Join(tempDir, "setup.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewHTTPServer(WithStaticRoot(tempDir), WithSearch(true), WithBaseURL("/docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// JSON results
+	req := httptest.NewRequest("GET", "/docs/_mdex/search?q=install&format=json", nil)
+	rr := httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, but got %d", http.StatusOK, rr.Code)
+	}
+
+	var response searchResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Total != 1 || len(response.Results) != 1 || response.Results[0].URL != "/setup" {
+		t.Errorf("Expected /setup as the only result, but got %+v", response)
+	}
+
+	// HTML results page, with links under the base path
+	req = httptest.NewRequest("GET", "/docs/_mdex/search?q=install", nil)
+	rr = httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(rr, req)
+
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected an HTML page, but got '%s'", ct)
+	}
+	for _, expected := range []string{`<a href="/docs/setup">Setup</a>`, "1 page found", `value="install"`} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("Expected the results page to contain '%s'", expected)
+		}
+	}
+
+	// The index is rebuilt when the pages change
+	if err := os.Remove(filepath.Join(tempDir, "setup.html")); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RebuildSearchIndex(); err != nil {
+		t.Fatal(err)
+	}
+	if results, total := srv.searchIndex.Load().Search("install", 10); total != 0 {
+		t.Errorf("Expected no results after the rebuild, but got %+v", results)
+	}
+}
+
+func TestHandleSearchDisabled(t *testing.T) {
+	srv, err := NewHTTPServer(WithStaticRoot(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/_mdex/search?q=install", nil)
+	rr := httptest.NewRecorder()
+	srv.Server.Handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestHandleSearchACL(t *testing.T) {
+	staticRoot := t.TempDir()
+	if err := os.Mkdir(filepath.Join(staticRoot, "security"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	pages := map[string]string{
+		"setup.html":              `<html><head><title>Setup</title></head><body><article><p>Rotate the logs.</p></article></body></html>`,
+		"security/incidents.html": `<html><head><title>Incidents</title></head><body><article><p>Rotate the keys.</p></article></body></html>`,
+	}
+	for name, page := range pages {
+		if err := os.WriteFile(filepath.Join(staticRoot, name), []byte(page), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srv := newACLServer(t, WithStaticRoot(staticRoot), WithSearch(true))
+
+	for user, expected := range map[string]int{"alice": 2, "bob": 1} {
+		req := httptest.NewRequest("GET", "/_mdex/search?q=rotate&format=json", nil)
+		req.SetBasicAuth(user, "secret")
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, req)
+
+		var response searchResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		if response.Total != expected || len(response.Results) != expected {
+			t.Errorf("Expected %d results for %s, but got %+v", expected, user, response)
+		}
+	}
+}
@@ -1,12 +1,28 @@
 package http
 
 import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"html/template"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/htpasswd"
+	"github.com/jpbruinsslot/mdex/oidc"
+	"github.com/jpbruinsslot/mdex/parser"
+	"github.com/jpbruinsslot/mdex/search"
 )
 
 type HTTPServer struct {
@@ -14,17 +30,69 @@ type HTTPServer struct {
 	Router     *http.ServeMux
 	Logger     *slog.Logger
 	StaticRoot string
+	LiveReload bool
+	// Search enables the search endpoint
+	Search bool
+	// Renderer, when set, renders the pages on request instead of serving
+	// them from the static root
+	Renderer Renderer
+	// BasePath is the path prefix the site is mounted under, without
+	// trailing slash, or empty when mounted at the root
+	BasePath   string
 	Middleware []Middleware
 	BasicAuth  struct {
 		Username string
 		Password string
 	}
+	// Htpasswd, when set, holds the users that may sign in with basic auth
+	Htpasswd *htpasswd.File
+	// ACL, when set, restricts sections of the site to users and groups
+	ACL *acl.ACL
+	// TrustedProxies are the networks of the reverse proxies whose
+	// ProxyHeaders identify users
+	TrustedProxies []*net.IPNet
+	ProxyHeaders   struct {
+		User   string
+		Groups string
+	}
+	// OIDC, when set, signs users in with an OpenID Connect provider
+	OIDC *oidc.Provider
+	// Compress compresses responses with gzip, and serves precompressed
+	// files when they are there
+	Compress bool
+
+	// ShutdownTimeout is how long Run waits for in-flight requests to
+	// finish when it stops
+	ShutdownTimeout time.Duration
+	// Redirect, when set, is the plain HTTP server that redirects to HTTPS
+	Redirect *http.Server
+
+	// certs is the certificate for HTTPS, or nil for plain HTTP
+	certs *certReloader
+
+	// sessionKey signs the session cookies, which are only sent over HTTPS
+	// when secureCookies is set
+	sessionKey    []byte
+	secureCookies bool
+
+	reloads *broadcaster
+	// done is closed on shutdown, to end the live reload event streams
+	done     chan struct{}
+	doneOnce sync.Once
+
+	// searchIndex is the index of the pages in the static root, replaced as
+	// a whole when they change
+	searchIndex atomic.Pointer[search.Index]
+	// templates render the search results page
+	templates map[string]*template.Template
 }
 
 func NewHTTPServer(opts ...Option) (*HTTPServer, error) {
 	options := &Options{
-		Port:       "8080",
-		StaticRoot: "public",
+		Port:            "8080",
+		StaticRoot:      "public",
+		ShutdownTimeout: 10 * time.Second,
+		Compress:        true,
 	}
 	for _, opt := range opts {
 		opt(options)
@@ -34,12 +102,36 @@ func NewHTTPServer(opts ...Option) (*HTTPServer, error) {
 
 	srv.Logger = slog.Default().With("module", "http")
 	srv.StaticRoot = options.StaticRoot
+	srv.LiveReload = options.LiveReload
+	srv.BasePath = parser.BasePath(options.BaseURL)
+	srv.ShutdownTimeout = options.ShutdownTimeout
+	srv.reloads = newBroadcaster()
+	srv.done = make(chan struct{})
+	srv.Renderer = options.Renderer
 
-	// Validate the static root directory
-	if err := srv.ValidateStaticRoot(); err != nil {
+	if srv.Renderer != nil {
+		// The search index is built from the generated pages
+		if options.Search {
+			return nil, fmt.Errorf("search requires generated pages, it can't be used when rendering on request")
+		}
+	} else if err := srv.ValidateStaticRoot(); err != nil {
+		// Validate the static root directory
 		return nil, fmt.Errorf("static root validation failed: %w", err)
 	}
 
+	if options.Search {
+		srv.Search = true
+		if err := srv.RebuildSearchIndex(); err != nil {
+			return nil, err
+		}
+
+		tmpls, err := parser.ParseTemplates()
+		if err != nil {
+			return nil, err
+		}
+		srv.templates = tmpls
+	}
+
 	// Set middleware
 	srv.Middleware = []Middleware{
 		srv.loggingMiddleware,
@@ -49,13 +141,107 @@ func NewHTTPServer(opts ...Option) (*HTTPServer, error) {
 	if options.Username != "" && options.Password != "" {
 		srv.BasicAuth.Username = options.Username
 		srv.BasicAuth.Password = options.Password
-		srv.Middleware = append(srv.Middleware, srv.basicAuthMiddleware)
 		srv.Logger.Info("Basic authentication enabled", "username", srv.BasicAuth.Username)
 	} else if options.Username != "" || options.Password != "" {
 		// Return an error if only one of username or password is provided
 		return nil, fmt.Errorf("basic auth requires both a username and a password")
 	}
 
+	if options.HtpasswdFile != "" {
+		file, err := htpasswd.Open(options.HtpasswdFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read htpasswd file: %w", err)
+		}
+		srv.Htpasswd = file
+		srv.Logger.Info("Basic authentication enabled", "htpasswd", options.HtpasswdFile)
+	}
+
+	// Configure the reverse proxies that identify users
+	if len(options.TrustedProxies) > 0 {
+		networks, err := parseNetworks(options.TrustedProxies)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+		}
+		srv.TrustedProxies = networks
+		srv.ProxyHeaders.User = cmp.Or(options.ProxyUserHeader, DefaultProxyUserHeader)
+		srv.ProxyHeaders.Groups = cmp.Or(options.ProxyGroupsHeader, DefaultProxyGroupsHeader)
+		srv.Logger.Info("Proxy authentication enabled", "proxies", options.TrustedProxies, "header", srv.ProxyHeaders.User)
+	}
+
+	// Configure the sign in with an OpenID Connect provider
+	if options.OIDC.Issuer != "" || options.OIDC.ClientID != "" {
+		config := options.OIDC
+		if config.Issuer == "" || config.ClientID == "" {
+			return nil, fmt.Errorf("OpenID Connect requires both an issuer and a client ID")
+		}
+		if config.UsernameClaim != "" && !slices.Contains(oidc.UsernameClaims, config.UsernameClaim) {
+			return nil, fmt.Errorf("unsupported OpenID Connect username claim %q, use one of %s", config.UsernameClaim, strings.Join(oidc.UsernameClaims, ", "))
+		}
+
+		if config.RedirectURL == "" {
+			u, err := url.Parse(options.BaseURL)
+			if err != nil || !u.IsAbs() {
+				return nil, fmt.Errorf("OpenID Connect requires a redirect URL, or a base URL with a scheme and host")
+			}
+			config.RedirectURL = strings.TrimSuffix(u.String(), "/") + callbackPath
+		}
+
+		srv.OIDC = oidc.New(config)
+		srv.sessionKey = newSessionKey(options.SessionSecret)
+		srv.secureCookies = strings.HasPrefix(config.RedirectURL, "https://")
+		srv.Logger.Info("OpenID Connect enabled", "issuer", config.Issuer, "redirect", config.RedirectURL)
+	}
+
+	if srv.hasBasicAuth() || srv.TrustedProxies != nil || srv.OIDC != nil {
+		srv.Middleware = append(srv.Middleware, srv.authMiddleware)
+	}
+
+	// Access rules apply to the signed-in users
+	if options.ACL != nil {
+		if !srv.hasBasicAuth() && srv.TrustedProxies == nil && srv.OIDC == nil {
+			return nil, fmt.Errorf("access rules require authentication")
+		}
+		srv.ACL = options.ACL
+		srv.Middleware = append(srv.Middleware, srv.aclMiddleware)
+		srv.Logger.Info("Access rules enabled", "rules", len(srv.ACL.Rules))
+	}
+
+	if options.Compress {
+		srv.Compress = true
+		srv.Middleware = append(srv.Middleware, srv.compressMiddleware)
+	}
+
+	// Configure HTTPS with certificate files, or a self-signed certificate
+	certFile, keyFile := options.TLSCertFile, options.TLSKeyFile
+	if options.TLSSelfSigned {
+		if certFile != "" || keyFile != "" {
+			return nil, fmt.Errorf("a self-signed certificate can't be combined with certificate files")
+		}
+
+		var err error
+		certFile, keyFile, err = selfSignedCert(options.TLSCacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate a self-signed certificate: %w", err)
+		}
+		srv.Logger.Info("Using a self-signed certificate", "cert", certFile)
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, fmt.Errorf("TLS requires both a certificate and a key file")
+		}
+
+		certs, err := newCertReloader(certFile, keyFile, srv.Logger)
+		if err != nil {
+			return nil, err
+		}
+		srv.certs = certs
+	}
+
+	if options.RedirectPort != "" && srv.certs == nil {
+		return nil, fmt.Errorf("redirecting to HTTPS requires TLS")
+	}
+
 	// Create the router
 	srv.Router = http.NewServeMux()
 	srv.RegisterRoutes()
@@ -88,10 +274,44 @@ func NewHTTPServer(opts ...Option) (*HTTPServer, error) {
 		MaxHeaderBytes: 1 << 20,
 	}
 
-	srv.Server.Handler = srv.Router
+	srv.Server.Handler = srv.mount(srv.Router)
+
+	if srv.certs != nil {
+		srv.Server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: srv.certs.GetCertificate,
+		}
+	}
+
+	if options.RedirectPort != "" {
+		srv.Redirect = &http.Server{
+			Addr:         fmt.Sprintf(":%s", options.RedirectPort),
+			Handler:      redirectToHTTPS(options.Port),
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  120 * time.Second,
+		}
+	}
+
 	return srv, nil
 }
 
+// mount serves the handler under the base path, with the base path stripped
+// from the request
+func (srv *HTTPServer) mount(handler http.Handler) http.Handler {
+	if srv.BasePath == "" {
+		return handler
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(srv.BasePath+"/", http.StripPrefix(srv.BasePath, handler))
+
+	// Send visitors of the root to the site
+	mux.Handle("GET /{$}", http.RedirectHandler(srv.BasePath+"/", http.StatusFound))
+
+	return mux
+}
+
 func (srv *HTTPServer) ValidateStaticRoot() error {
 	info, err := os.Stat(srv.StaticRoot)
 	if err != nil {
@@ -103,7 +323,87 @@ func (srv *HTTPServer) ValidateStaticRoot() error {
 	return nil
 }
 
-func (srv *HTTPServer) Run() {
-	srv.Logger.Info("Server is running", "addr", srv.Server.Addr)
-	log.Fatal(srv.Server.ListenAndServe())
+// Run serves until the context is cancelled, after which in-flight requests
+// are given ShutdownTimeout to finish. It returns an error when the server
+// can't be started or fails, like when the port is already in use.
+func (srv *HTTPServer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ln, err := net.Listen("tcp", srv.Server.Addr)
+	if err != nil {
+		return err
+	}
+
+	var redirectLn net.Listener
+	if srv.Redirect != nil {
+		if redirectLn, err = net.Listen("tcp", srv.Redirect.Addr); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	if srv.Search {
+		go func() {
+			if err := srv.watchSearchIndex(ctx); err != nil {
+				srv.Logger.Error("Watching the static root failed", "error", err)
+			}
+		}()
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		if srv.certs != nil {
+			// The certificate comes from the TLS config
+			errs <- srv.Server.ServeTLS(ln, "", "")
+		} else {
+			errs <- srv.Server.Serve(ln)
+		}
+	}()
+
+	if redirectLn != nil {
+		go func() {
+			errs <- srv.Redirect.Serve(redirectLn)
+		}()
+		srv.Logger.Info("Redirecting to HTTPS", "addr", redirectLn.Addr().String())
+	}
+
+	srv.Logger.Info("Server is running", "addr", ln.Addr().String(), "tls", srv.certs != nil)
+
+	var serveErr error
+	select {
+	case serveErr = <-errs:
+		// The server was shut down by a call to Shutdown
+		if errors.Is(serveErr, http.ErrServerClosed) {
+			return nil
+		}
+	case <-ctx.Done():
+	}
+
+	srv.Logger.Info("Shutting down", "timeout", srv.ShutdownTimeout)
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), srv.ShutdownTimeout)
+	defer cancelShutdown()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return errors.Join(serveErr, fmt.Errorf("failed to shut down: %w", err))
+	}
+
+	srv.Logger.Info("Server stopped")
+	return serveErr
+}
+
+// Shutdown stops the server gracefully. It stops accepting connections, ends
+// the live reload event streams, and waits for in-flight requests to finish
+// until the context is done.
+func (srv *HTTPServer) Shutdown(ctx context.Context) error {
+	srv.doneOnce.Do(func() {
+		close(srv.done)
+	})
+
+	var redirectErr error
+	if srv.Redirect != nil {
+		redirectErr = srv.Redirect.Shutdown(ctx)
+	}
+	return errors.Join(srv.Server.Shutdown(ctx), redirectErr)
 }
@@ -1,9 +1,16 @@
 package http
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewHTTPServer(t *testing.T) {
@@ -83,4 +90,159 @@ func TestNewHTTPServer_IncompleteBasicAuth(t *testing.T) {
 	if !strings.Contains(err.Error(), expected) {
 		t.Errorf("expected error to contain \"%s\", but got \"%s\"", expected, err.Error())
 	}
-}
\ No newline at end of file
+}
+
+func TestNewHTTPServer_BaseURL(t *testing.T) {
+	tempDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tempDir, "guide.html"), []byte("Guide"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, err := NewHTTPServer(WithStaticRoot(tempDir), WithBaseURL("https://intranet/eng/docs/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if srv.BasePath != "/eng/docs" {
+		t.Errorf("Expected BasePath to be '/eng/docs', but got '%s'", srv.BasePath)
+	}
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/eng/docs/guide", http.StatusOK, ""},
+		{"/eng/docs/static/css/main.css", http.StatusOK, ""},
+		{"/guide", http.StatusNotFound, ""},
+		{"/", http.StatusFound, "/eng/docs/"},
+	}
+
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		srv.Server.Handler.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+		if rr.Code != tt.status {
+			t.Errorf("Expected status %d for '%s', but got %d", tt.status, tt.path, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != tt.location {
+			t.Errorf("Expected location '%s' for '%s', but got '%s'", tt.location, tt.path, location)
+		}
+	}
+}
+
+// freePort returns a port that is free to listen on
+func freePort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return port
+}
+
+func TestRun_DrainsRequests(t *testing.T) {
+	port := freePort(t)
+	srv, err := NewHTTPServer(WithStaticRoot(t.TempDir()), WithPort(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan struct{})
+	srv.Router.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- srv.Run(ctx)
+	}()
+
+	// Wait for the server to accept connections
+	var conn net.Conn
+	for i := 0; i < 50; i++ {
+		if conn, err = net.Dial("tcp", "127.0.0.1:"+port); err == nil {
+			conn.Close()
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Server didn't start: %v", err)
+	}
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://127.0.0.1:" + port + "/slow")
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	// Stop the server while the request is in flight
+	<-started
+	cancel()
+
+	if body := <-responses; body != "done" {
+		t.Errorf("Expected the in-flight request to finish, but got '%s'", body)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Expected Run to return without error, but got %v", err)
+	}
+}
+
+func TestRun_PortInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	srv, err := NewHTTPServer(WithStaticRoot(t.TempDir()), WithPort(port))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := srv.Run(context.Background()); err == nil {
+		t.Error("Expected an error when the port is in use")
+	}
+}
+
+func TestShutdown_EndsEventStreams(t *testing.T) {
+	srv, err := NewHTTPServer(WithStaticRoot(t.TempDir()), WithLiveReload(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		rr := httptest.NewRecorder()
+		srv.handleEvents().ServeHTTP(rr, httptest.NewRequest("GET", "/_mdex/events", nil))
+		close(done)
+	}()
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected the event stream to end on shutdown")
+	}
+}
@@ -0,0 +1,195 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedCert(t *testing.T) {
+	dir := t.TempDir()
+
+	certFile, keyFile, err := selfSignedCert(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(cert.Leaf.DNSNames, "localhost") {
+		t.Errorf("Expected a certificate for localhost, but got %v", cert.Leaf.DNSNames)
+	}
+
+	// The certificate is reused until it expires
+	before, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := selfSignedCert(dir); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("Expected the cached certificate to be reused")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	firstCert, firstKey, err := selfSignedCert(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secondCert, secondKey, err := selfSignedCert(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	copyFile(t, firstCert, certFile)
+	copyFile(t, firstKey, keyFile)
+
+	certs, err := newCertReloader(certFile, keyFile, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := certs.GetCertificate(nil)
+
+	// Rotate the certificate
+	copyFile(t, secondCert, certFile)
+	copyFile(t, secondKey, keyFile)
+	later := time.Now().Add(time.Minute)
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	second, _ := certs.GetCertificate(nil)
+	if second.Leaf.SerialNumber.Cmp(first.Leaf.SerialNumber) == 0 {
+		t.Error("Expected the rotated certificate to be loaded")
+	}
+
+	// A broken certificate keeps the current one in use
+	if err := os.WriteFile(certFile, []byte("broken"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := certs.GetCertificate(nil); current != second {
+		t.Error("Expected the current certificate to be kept")
+	}
+}
+
+func TestNewHTTPServer_TLSOptions(t *testing.T) {
+	tempDir := t.TempDir()
+
+	tests := map[string][]Option{
+		"missing key":             {WithTLS("cert.pem", "")},
+		"missing files":           {WithTLS("missing.pem", "missing-key.pem")},
+		"self-signed with files":  {WithTLS("cert.pem", "key.pem"), WithSelfSignedTLS(tempDir)},
+		"redirect without TLS":    {WithHTTPRedirect("8081")},
+		"self-signed in bad path": {WithSelfSignedTLS(filepath.Join(tempDir, "file", "dir"))},
+	}
+
+	if err := os.WriteFile(filepath.Join(tempDir, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range tests {
+		if _, err := NewHTTPServer(append(opts, WithStaticRoot(tempDir))...); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestRun_TLS(t *testing.T) {
+	port := freePort(t)
+	srv, err := NewHTTPServer(WithStaticRoot(t.TempDir()), WithPort(port), WithSelfSignedTLS(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- srv.Run(ctx)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	var resp *http.Response
+	for i := 0; i < 50; i++ {
+		if resp, err = client.Get("https://127.0.0.1:" + port + "/static/css/main.css"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Expected an HTTPS response, but got %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Errorf("Expected status %d over TLS, but got %d", http.StatusOK, resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-stopped; err != nil {
+		t.Errorf("Expected Run to return without error, but got %v", err)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		host     string
+		port     string
+		expected string
+	}{
+		{"docs.local:8080", "8443", "https://docs.local:8443/guide?page=2"},
+		{"docs.local", "443", "https://docs.local/guide?page=2"},
+		{"[::1]:8080", "8443", "https://[::1]:8443/guide?page=2"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/guide?page=2", nil)
+		req.Host = tt.host
+		rr := httptest.NewRecorder()
+		redirectToHTTPS(tt.port).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusMovedPermanently {
+			t.Errorf("Expected status %d, but got %d", http.StatusMovedPermanently, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != tt.expected {
+			t.Errorf("Expected location '%s' for '%s', but got '%s'", tt.expected, tt.host, location)
+		}
+	}
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,12 @@
 package mdex
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jpbruinsslot/mdex/acl"
 	"github.com/jpbruinsslot/mdex/http"
 	"github.com/jpbruinsslot/mdex/parser"
 )
@@ -15,14 +21,32 @@ func Generate(mdParser parser.MarkdownParser, opts ...parser.Option) error {
 	return p.Generate()
 }
 
+// GenerateForGroups generates a build of the site for every group of the ACL,
+// and one for everyone else, each leaving out the pages its readers can't
+// access. See parser.GenerateForGroups.
+func GenerateForGroups(mdParser parser.MarkdownParser, a *acl.ACL, opts ...parser.Option) error {
+	return parser.GenerateForGroups(mdParser, a, opts...)
+}
+
+// Check reports the broken links in the markdown files, without generating
+// the site
+func Check(mdParser parser.MarkdownParser, opts ...parser.Option) (*parser.CheckReport, error) {
+	p := parser.New(mdParser, opts...)
+	return p.Check()
+}
+
+// Serve serves the site until the process receives SIGINT or SIGTERM, after
+// which in-flight requests are given time to finish
 func Serve(opts ...http.Option) error {
 	srv, err := http.NewHTTPServer(opts...)
 	if err != nil {
 		return err
 	}
 
-	srv.Run()
-	return nil
+	ctx, stop := signalContext()
+	defer stop()
+
+	return srv.Run(ctx)
 }
 
 func GenerateAndServe(mdParser parser.MarkdownParser, parserOpts []parser.Option, serverOpts []http.Option) error {
@@ -33,3 +57,68 @@ func GenerateAndServe(mdParser parser.MarkdownParser, parserOpts []parser.Option
 
 	return Serve(serverOpts...)
 }
+
+// RenderAndServe serves the site, rendering the pages from the markdown files
+// on request instead of generating them up front. Pages are rendered again
+// when their source or the navigation of the site changes.
+func RenderAndServe(mdParser parser.MarkdownParser, parserOpts []parser.Option, serverOpts []http.Option) error {
+	p := parser.New(mdParser, parserOpts...)
+
+	renderer, err := parser.NewRenderer(p)
+	if err != nil {
+		return err
+	}
+
+	return Serve(append(serverOpts, http.WithRenderer(renderer))...)
+}
+
+// Watch generates the site and regenerates the affected outputs whenever the
+// markdown files change, until the context is cancelled.
+func Watch(ctx context.Context, mdParser parser.MarkdownParser, opts ...parser.Option) error {
+	p := parser.New(mdParser, opts...)
+	if err := p.Generate(); err != nil {
+		return err
+	}
+
+	return p.Watch(ctx)
+}
+
+// WatchAndServe generates and serves the site, and regenerates the affected
+// outputs whenever the markdown files change. When live reload is enabled on
+// the server, open pages are notified after every regeneration.
+func WatchAndServe(mdParser parser.MarkdownParser, parserOpts []parser.Option, serverOpts []http.Option) error {
+	p := parser.New(mdParser, parserOpts...)
+	if err := p.Generate(); err != nil {
+		return err
+	}
+
+	srv, err := http.NewHTTPServer(serverOpts...)
+	if err != nil {
+		return err
+	}
+
+	p.OnRegenerate = func(changes parser.Changes) {
+		srv.BroadcastReload(http.ReloadEvent{
+			Routes:     changes.Routes,
+			Structural: changes.Structural,
+			All:        changes.All,
+		})
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	go func() {
+		if err := p.Watch(ctx); err != nil {
+			p.Logger.Error("Watching failed", "error", err)
+		}
+	}()
+
+	return srv.Run(ctx)
+}
+
+// signalContext returns a context that is cancelled when the process receives
+// SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
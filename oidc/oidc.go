@@ -0,0 +1,332 @@
+// Package oidc signs users in with an OpenID Connect provider, using the
+// authorization code flow with PKCE. The ID tokens returned by the provider
+// are verified with the keys it publishes.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultScopes are requested when the config has none
+var DefaultScopes = []string{"openid", "profile", "email"}
+
+// UsernameClaims are the claims users can be known by. The subject is the
+// default, since it is the only claim that is unique and never changes. The
+// email address is only used when the provider verified it, and the
+// preferred username only suits providers that don't let users pick it.
+var UsernameClaims = []string{"sub", "email", "preferred_username"}
+
+// Config is the client registration at the provider
+type Config struct {
+	// Issuer is the URL of the provider, its configuration is read from
+	// /.well-known/openid-configuration below it
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back to after they
+	// signed in
+	RedirectURL string
+	Scopes      []string
+	// UsernameClaim is the claim users are known by, one of UsernameClaims.
+	// It is sub when empty.
+	UsernameClaim string
+	// HTTPClient is used for the requests to the provider, or
+	// http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// Provider is an OpenID Connect provider. Its configuration and keys are read
+// when they are first needed, so it can be created while the provider is
+// unreachable.
+type Provider struct {
+	config Config
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]any
+}
+
+// metadata is the part of the provider configuration that is used
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the claims of a verified ID token
+type Claims struct {
+	Issuer            string    `json:"iss"`
+	Subject           string    `json:"sub"`
+	Audience          audience  `json:"aud"`
+	Expiry            timestamp `json:"exp"`
+	Nonce             string    `json:"nonce"`
+	Email             string    `json:"email"`
+	EmailVerified     boolean   `json:"email_verified"`
+	PreferredUsername string    `json:"preferred_username"`
+	Name              string    `json:"name"`
+	Groups            []string  `json:"groups"`
+}
+
+// Username returns the name the user is known by, which is the claim of the
+// config. It fails when the claim isn't set, or for an email address that
+// the provider didn't verify.
+func (p *Provider) Username(c *Claims) (string, error) {
+	var name string
+	switch p.config.UsernameClaim {
+	case "sub":
+		name = c.Subject
+	case "email":
+		if !c.EmailVerified {
+			return "", errors.New("ID token has no verified email address")
+		}
+		name = c.Email
+	case "preferred_username":
+		name = c.PreferredUsername
+	default:
+		return "", fmt.Errorf("unsupported username claim %q", p.config.UsernameClaim)
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("ID token has no %s claim", p.config.UsernameClaim)
+	}
+	return name, nil
+}
+
+// New returns the provider of the config
+func New(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultScopes
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "sub"
+	}
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	return &Provider{config: config}
+}
+
+// AuthCodeURL returns the URL of the provider that users sign in at. The state
+// and nonce are returned as they are, and the challenge is the one of the
+// PKCE verifier, see Challenge.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return m.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades the code the provider sent the user back with for an ID
+// token, and returns its claims once it is verified. The verifier is the PKCE
+// verifier of the challenge, and the nonce the one of AuthCodeURL.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Claims, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.do(req, &token); err != nil {
+		if token.Error != "" {
+			return nil, fmt.Errorf("token request failed: %s %s", token.Error, token.ErrorDescription)
+		}
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no ID token")
+	}
+
+	claims, err := p.verify(ctx, token.IDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Nonce != nonce {
+		return nil, errors.New("ID token has an unexpected nonce")
+	}
+	return claims, nil
+}
+
+// verify checks the signature and claims of an ID token
+func (p *Provider) verify(ctx context.Context, idToken string) (*Claims, error) {
+	m, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := p.verifySignature(ctx, idToken, &claims); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case claims.Issuer != m.Issuer:
+		return nil, fmt.Errorf("ID token is issued by %q instead of %q", claims.Issuer, m.Issuer)
+	case !claims.Audience.contains(p.config.ClientID):
+		return nil, errors.New("ID token is not meant for this client")
+	case time.Now().After(claims.Expiry.Time()):
+		return nil, errors.New("ID token has expired")
+	case claims.Subject == "":
+		return nil, errors.New("ID token has no subject")
+	}
+
+	return &claims, nil
+}
+
+// discover reads the configuration of the provider, once it succeeds
+func (p *Provider) discover(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var m metadata
+	if err := p.do(req, &m); err != nil {
+		return nil, fmt.Errorf("failed to read the provider configuration: %w", err)
+	}
+
+	if m.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("provider configuration is for issuer %q instead of %q", m.Issuer, p.config.Issuer)
+	}
+	if m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "" {
+		return nil, errors.New("provider configuration is incomplete")
+	}
+
+	p.metadata = &m
+	return p.metadata, nil
+}
+
+// do sends a request to the provider and decodes its JSON response into v.
+// Error responses are decoded as well, so their details can be reported.
+func (p *Provider) do(req *http.Request, v any) error {
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	decodeErr := json.Unmarshal(body, v)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s responded with %s", req.URL.Redacted(), resp.Status)
+	}
+	return decodeErr
+}
+
+// RandomString returns a random URL-safe string, for states, nonces and PKCE
+// verifiers
+func RandomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Challenge returns the S256 PKCE challenge of a verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// audience is the aud claim, which is a string or a list of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func (a audience) contains(clientID string) bool {
+	return slices.Contains(a, clientID)
+}
+
+// boolean is a claim that is true or false, which some providers send as a
+// string
+type boolean bool
+
+func (b *boolean) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = s == "true"
+		return nil
+	}
+	return json.Unmarshal(data, (*bool)(b))
+}
+
+// timestamp is a claim in seconds since the epoch
+type timestamp float64
+
+func (t timestamp) Time() time.Time {
+	return time.Unix(int64(t), 0)
+}
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jpbruinsslot/mdex/acl"
+)
+
+// isAllowed reports whether the file or directory at path can be accessed
+// with the access the site is generated for
+func (p *Parser) isAllowed(path string, isDir bool) bool {
+	if p.Access.ACL() == nil {
+		return true
+	}
+
+	route := p.routeFor(path)
+	if !isDir && !p.isMarkdownFile(path) {
+		// Assets are served at their own path
+		route = "/" + filepath.ToSlash(p.relSourcePath(path))
+	}
+	return p.Access.Allowed(route)
+}
+
+// withAccess returns a parser with the same options, that renders the site as
+// it is seen with the access
+func (p *Parser) withAccess(access acl.Access) *Parser {
+	options := p.options
+	options.Access = access
+
+	q := New(p.Parser, func(o *Options) { *o = options })
+	q.Logger = p.Logger
+	q.Templates = p.Templates
+	q.now = p.now
+	return q
+}
+
+// GenerateForGroups generates a build of the site for the members of every
+// group of the ACL, in a directory named after the group in the output
+// directory, and one for everyone else in acl.Everyone. Every build leaves
+// out the pages its readers can't access. Since the builds are made per
+// group, rules can't name users.
+func GenerateForGroups(mdParser MarkdownParser, a *acl.ACL, opts ...Option) error {
+	for i, rule := range a.Rules {
+		if len(rule.Users) > 0 {
+			return fmt.Errorf("rule %d: path %q names users, but a build is generated per group, serve the site with --root instead", i+1, rule.Path)
+		}
+	}
+
+	groups := append(a.GroupNames(), acl.Everyone)
+	for _, group := range groups {
+		user := acl.User{Groups: []string{group}}
+		if group == acl.Everyone {
+			user = acl.User{}
+		}
+
+		p := New(mdParser, append(opts, WithAccess(a.Access(user)))...)
+		p.OutputPath = filepath.Join(p.outputRoot, group)
+		p.Logger = p.Logger.With("group", group)
+
+		if err := p.Generate(); err != nil {
+			return fmt.Errorf("build for %s: %w", group, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,272 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jpbruinsslot/mdex/acl"
+)
+
+const testACL = `
+groups:
+  security: [alice]
+rules:
+  - path: /security/
+    groups: [security]
+  - path: /hr/*.pdf
+    groups: [hr]
+`
+
+// writeACLSite writes a site with a public guide, a security section and a
+// restricted download
+func writeACLSite(t *testing.T) string {
+	t.Helper()
+	rootDir := t.TempDir()
+
+	for _, dir := range []string{"guide", "security", "hr"} {
+		if err := os.Mkdir(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(rootDir, "index.md"), "# Home")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup\n\nRun make.")
+	writeFile(t, filepath.Join(rootDir, "security", "incidents.md"), "# Incidents\n\nRotate the keys.")
+	writeFile(t, filepath.Join(rootDir, "hr", "handbook.md"), "# Handbook")
+	writeFile(t, filepath.Join(rootDir, "hr", "salaries.pdf"), "%PDF")
+
+	return rootDir
+}
+
+func TestGenerateForGroups(t *testing.T) {
+	rootDir := writeACLSite(t)
+	outputDir := filepath.Join(rootDir, "public")
+
+	a, err := acl.Parse([]byte(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = GenerateForGroups(NewGoldmarkParser(), a, WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		build    string
+		file     string
+		expected bool
+	}{
+		{"security", "guide/setup.html", true},
+		{"security", "security/incidents.html", true},
+		{"security", "hr/salaries.pdf", false},
+		{"hr", "security/incidents.html", false},
+		{"hr", "security/index.html", false},
+		{"hr", "hr/salaries.pdf", true},
+		{acl.Everyone, "guide/setup.html", true},
+		{acl.Everyone, "hr/handbook.html", true},
+		{acl.Everyone, "security/incidents.html", false},
+		{acl.Everyone, "hr/salaries.pdf", false},
+	}
+
+	for _, tt := range tests {
+		_, err := os.Stat(filepath.Join(outputDir, tt.build, tt.file))
+		if exists := err == nil; exists != tt.expected {
+			t.Errorf("Expected %s in the build for %s to exist: %v, but got %v", tt.file, tt.build, tt.expected, exists)
+		}
+	}
+
+	// Restricted pages are left out of the navigation and the search index
+	// of the builds that can't access them
+	page, err := os.ReadFile(filepath.Join(outputDir, acl.Everyone, "guide", "setup.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(page), "/security/") {
+		t.Error("Expected the navigation of everyone to leave out /security/")
+	}
+
+	page, err = os.ReadFile(filepath.Join(outputDir, "security", "guide", "setup.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "/security/incidents") {
+		t.Error("Expected the navigation of the security group to include /security/incidents")
+	}
+
+	for build, expected := range map[string]bool{"security": true, acl.Everyone: false} {
+		if found := searchIndexContains(t, filepath.Join(outputDir, build), "rotate"); found != expected {
+			t.Errorf("Expected the search index of %s to contain 'rotate': %v, but got %v", build, expected, found)
+		}
+	}
+
+	// The builds don't include each other
+	if _, err := os.Stat(filepath.Join(outputDir, "security", "hr", "security")); err == nil {
+		t.Error("Expected the builds not to be part of each other")
+	}
+}
+
+func TestGenerateForGroups_UserRules(t *testing.T) {
+	rootDir := writeACLSite(t)
+
+	a, err := acl.Parse([]byte(testACL + "  - path: /guide/\n    users: [\"*\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = GenerateForGroups(NewGoldmarkParser(), a, WithRootPath(rootDir), WithOutputPath(filepath.Join(rootDir, "public")))
+	if err == nil || !strings.Contains(err.Error(), `"/guide/" names users`) {
+		t.Errorf("Expected an error about the rule with users, but got %v", err)
+	}
+}
+
+// searchIndexContains reports whether a term is in the search index of a
+// build
+func searchIndexContains(t *testing.T, outputDir, term string) bool {
+	t.Helper()
+
+	shards, err := filepath.Glob(filepath.Join(outputDir, SearchDir, "terms", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, shard := range shards {
+		content, err := os.ReadFile(shard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(content), `"`+term+`"`) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRenderFor(t *testing.T) {
+	rootDir := writeACLSite(t)
+
+	a, err := acl.Parse([]byte(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithAccess(a.Access(acl.User{})))
+	r, err := NewRenderer(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := acl.User{Name: "alice"}
+	bob := acl.User{Name: "bob"}
+
+	page, err := r.RenderFor("/guide/setup", alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `href="/security/incidents"`) {
+		t.Error("Expected the navigation of alice to include /security/incidents")
+	}
+
+	page, err = r.RenderFor("/guide/setup", bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(page), "/security/") {
+		t.Error("Expected the navigation of bob to leave out /security/")
+	}
+
+	if _, err := r.RenderFor("/security/incidents", alice); err != nil {
+		t.Errorf("Expected alice to get /security/incidents, but got %v", err)
+	}
+	for _, route := range []string{"/security/incidents", "/security/"} {
+		if _, err := r.RenderFor(route, bob); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected %s to be not found for bob, but got %v", route, err)
+		}
+		if _, err := r.Render(route); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected %s to be not found without a user, but got %v", route, err)
+		}
+	}
+
+	// Users with the same access share a renderer
+	r.RenderFor("/", acl.User{Name: "eve", Groups: []string{"security"}})
+	if len(r.accesses) != 1 {
+		t.Errorf("Expected 1 renderer for other accesses, but got %d", len(r.accesses))
+	}
+}
+
+func TestRenderFor_Evict(t *testing.T) {
+	rootDir := writeACLSite(t)
+
+	a, err := acl.Parse([]byte(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithAccess(a.Access(acl.User{})))
+	r, err := NewRenderer(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Fill the renderers for other accesses, the first one is used last
+	for i := range maxAccessRenderers {
+		r.accesses[fmt.Sprintf("access-%d", i)] = &accessRenderer{used: uint64(i + 2)}
+	}
+	r.accesses["access-0"].used = uint64(maxAccessRenderers + 2)
+	r.uses = uint64(maxAccessRenderers + 2)
+
+	if _, err := r.RenderFor("/guide/setup", acl.User{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.accesses) != maxAccessRenderers {
+		t.Errorf("Expected %d renderers for other accesses, but got %d", maxAccessRenderers, len(r.accesses))
+	}
+	if _, ok := r.accesses["access-1"]; ok {
+		t.Error("Expected the least recently used renderer to be dropped")
+	}
+	if _, ok := r.accesses["access-0"]; !ok {
+		t.Error("Expected the recently used renderer to be kept")
+	}
+	if _, ok := r.accesses[a.Access(acl.User{Name: "alice"}).Key()]; !ok {
+		t.Error("Expected a renderer for the access of alice")
+	}
+}
+
+func TestRenderFor_Concurrent(t *testing.T) {
+	rootDir := writeACLSite(t)
+	writeFile(t, filepath.Join(rootDir, "guide", "links.md"), "# Links\n\nSee [the setup](/guide/setup).")
+
+	a, err := acl.Parse([]byte(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithBaseURL("/docs"), WithAccess(a.Access(acl.User{})))
+	r, err := NewRenderer(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Renderers for other accesses are created while pages are rendered
+	var wg sync.WaitGroup
+	for _, user := range []acl.User{{}, {Name: "alice"}, {Name: "bob", Groups: []string{"hr"}}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			page, err := r.RenderFor("/guide/links", user)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !strings.Contains(string(page), `href="/docs/guide/setup"`) {
+				t.Errorf("Expected the link to be prefixed with the base path for %+v", user)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jpbruinsslot/mdex/http/assets"
+)
+
+// StaticDir is the directory in the output with the stylesheets, scripts and
+// icons of the templates
+const StaticDir = "static"
+
+// assetExts are the extensions of the files that are copied to the output as
+// they are. Other files, like a Makefile or keys, are never published.
+var assetExts = map[string]bool{
+	// Images
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".svg": true,
+	".webp": true, ".avif": true, ".ico": true, ".bmp": true,
+	// Documents
+	".pdf": true, ".txt": true, ".csv": true, ".epub": true,
+	".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true,
+	".pptx": true, ".odt": true, ".ods": true, ".odp": true,
+	// Audio and video
+	".mp3": true, ".wav": true, ".ogg": true, ".m4a": true, ".mp4": true,
+	".webm": true, ".mov": true,
+	// Fonts
+	".woff": true, ".woff2": true, ".ttf": true, ".otf": true,
+	// Archives
+	".zip": true, ".tar": true, ".gz": true, ".tgz": true, ".bz2": true,
+	".xz": true, ".7z": true,
+	// Web pages, stylesheets and scripts
+	".html": true, ".htm": true, ".css": true, ".js": true,
+}
+
+// isAsset reports whether the file is copied to the output as is, like
+// images, PDFs and other downloads next to the markdown files. Excluded files,
+// like the htpasswd file, never are.
+func (p *Parser) isAsset(path string) bool {
+	name := filepath.Base(path)
+	if p.isMarkdownFile(name) || p.isIgnored(name) || !assetExts[strings.ToLower(filepath.Ext(name))] {
+		return false
+	}
+	return !p.isExcluded(path)
+}
+
+// isExcluded reports whether the file is one of the ExcludedFiles
+func (p *Parser) isExcluded(path string) bool {
+	if len(p.ExcludedFiles) == 0 {
+		return false
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return true
+	}
+	for _, excluded := range p.ExcludedFiles {
+		if absExcluded, err := filepath.Abs(excluded); err == nil && absExcluded == absPath {
+			return true
+		}
+	}
+	return false
+}
+
+// assetConflict returns what else is written to the output path of the
+// asset, like the page of foo.md for foo.html, or a stylesheet of the
+// templates for static/css/main.css
+func (p *Parser) assetConflict(path string) (string, bool) {
+	relPath := p.relSourcePath(path)
+	if name, ok := strings.CutPrefix(relPath, StaticDir+"/"); ok {
+		if _, err := fs.Stat(assets.FS, name); err == nil {
+			return "the templates", true
+		}
+	}
+
+	if filepath.Ext(path) != ".html" {
+		return "", false
+	}
+
+	dir := filepath.Dir(path)
+	name := strings.TrimSuffix(filepath.Base(path), ".html")
+	if name == "index" {
+		if page, ok := p.indexPageFor(dir); ok {
+			return p.relSourcePath(page), true
+		}
+		return "", false
+	}
+
+	for _, ext := range []string{".md", ".markdown", ".mkd"} {
+		page := filepath.Join(dir, name+ext)
+		if info, err := os.Stat(page); err == nil && !info.IsDir() && !p.isIgnored(name+ext) {
+			return p.relSourcePath(page), true
+		}
+	}
+	return "", false
+}
+
+// copyAsset copies a non-markdown file to the same relative path in the
+// output directory. Assets that would overwrite another output aren't copied.
+func (p *Parser) copyAsset(path string) error {
+	if conflict, ok := p.assetConflict(path); ok {
+		return stageError(StageSave, fmt.Errorf("output is generated from %s as well", conflict))
+	}
+
+	outputPath, err := p.assetOutputPath(path)
+	if err != nil {
+		return stageError(StageRead, err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return stageError(StageRead, err)
+	}
+
+	entry := ManifestEntry{
+		Source:     p.relSourcePath(path),
+		SourceHash: hashBytes(content),
+	}
+	if p.isUpToDate(outputPath, entry) {
+		p.Logger.Debug("Unchanged", "file", path)
+		return nil
+	}
+
+	p.Logger.Info("Copying", "file", path)
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return stageError(StageSave, err)
+	}
+
+	if err := os.WriteFile(outputPath, content, 0644); err != nil {
+		return stageError(StageSave, err)
+	}
+
+	p.record(outputPath, entry)
+	return nil
+}
+
+// assetOutputPath returns the path an asset is copied to
+func (p *Parser) assetOutputPath(path string) (string, error) {
+	relPath, err := filepath.Rel(p.RootPath, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(p.OutputPath, relPath), nil
+}
+
+// writeStaticAssets writes the stylesheets, scripts and icons used by the
+// templates to the output, so the site also works when it is hosted without
+// mdex serve
+func (p *Parser) writeStaticAssets() error {
+	return fs.WalkDir(assets.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) == ".go" {
+			return err
+		}
+
+		content, err := fs.ReadFile(assets.FS, path)
+		if err != nil {
+			return err
+		}
+
+		return writeFileIfChanged(filepath.Join(p.OutputPath, StaticDir, filepath.FromSlash(path)), content)
+	})
+}
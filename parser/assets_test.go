@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGenerateCopiesAssets(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	for _, dir := range []string{"img", "_drafts", ".git"} {
+		if err := os.Mkdir(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(rootDir, "page.md"), "![diagram](img/arch.png)")
+	writeFile(t, filepath.Join(rootDir, "img", "arch.png"), "png")
+	writeFile(t, filepath.Join(rootDir, "manual.pdf"), "pdf")
+	writeFile(t, filepath.Join(rootDir, "_notes.txt"), "notes")
+	writeFile(t, filepath.Join(rootDir, ".env"), "secret")
+	writeFile(t, filepath.Join(rootDir, "_drafts", "draft.png"), "png")
+	writeFile(t, filepath.Join(rootDir, ".git", "config"), "config")
+	writeFile(t, filepath.Join(rootDir, "Makefile"), "all:")
+	writeFile(t, filepath.Join(rootDir, "server.pem"), "key")
+	writeFile(t, filepath.Join(rootDir, "id_rsa"), "key")
+	writeFile(t, filepath.Join(rootDir, "acl.yaml"), "rules:")
+	writeFile(t, filepath.Join(rootDir, "users.txt"), "alice:hash")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir),
+		WithExcludedFiles(filepath.Join(rootDir, "users.txt")))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"img/arch.png", "manual.pdf"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("Expected '%s' to be copied: %v", name, err)
+			continue
+		}
+		if string(content) != filepath.Ext(name)[1:] {
+			t.Errorf("Expected '%s' to be copied as is, but got '%s'", name, content)
+		}
+	}
+
+	ignored := []string{
+		"_notes.txt", ".env", "_drafts/draft.png", ".git/config",
+		// Only files with the extensions of assets are copied
+		"Makefile", "server.pem", "id_rsa", "acl.yaml",
+		// Excluded files never are
+		"users.txt",
+	}
+	for _, name := range ignored {
+		if _, err := os.Stat(filepath.Join(outputDir, filepath.FromSlash(name))); err == nil {
+			t.Errorf("Expected ignored '%s' not to be copied", name)
+		}
+	}
+
+	// Removed assets are removed from the output by the next build
+	if err := os.Remove(filepath.Join(rootDir, "manual.pdf")); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "manual.pdf")); err == nil {
+		t.Error("Expected 'manual.pdf' to be removed from the output")
+	}
+}
+
+func TestRegenerateAssets(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "page.md"), "# Page")
+	writeFile(t, filepath.Join(rootDir, "logo.svg"), "v1")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	assetPath := filepath.Join(rootDir, "logo.svg")
+	outputPath := filepath.Join(outputDir, "logo.svg")
+
+	writeFile(t, assetPath, "v2")
+	changes, err := p.Regenerate([]string{assetPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.All {
+		t.Error("Expected the change to be reported as an asset change")
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "v2" {
+		t.Errorf("Expected the asset to be updated to 'v2', but got '%s'", content)
+	}
+
+	if err := os.Remove(assetPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Regenerate([]string{assetPath}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		t.Error("Expected the removed asset to be removed from the output")
+	}
+}
+
+func TestGenerateAssetConflicts(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	for _, dir := range []string{"guide", "static/css", "static/img"} {
+		if err := os.MkdirAll(filepath.Join(rootDir, filepath.FromSlash(dir)), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(rootDir, "setup.md"), "# Setup")
+	writeFile(t, filepath.Join(rootDir, "setup.html"), "copied")
+	writeFile(t, filepath.Join(rootDir, "guide", "index.md"), "# Guide")
+	writeFile(t, filepath.Join(rootDir, "guide", "index.html"), "copied")
+	writeFile(t, filepath.Join(rootDir, "static", "css", "main.css"), "copied")
+	writeFile(t, filepath.Join(rootDir, "static", "img", "logo.png"), "png")
+	writeFile(t, filepath.Join(rootDir, "about.html"), "copied")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	err := p.Generate()
+
+	var buildErrs BuildErrors
+	if !errors.As(err, &buildErrs) {
+		t.Fatalf("Expected BuildErrors, but got %v", err)
+	}
+	var failed []string
+	for _, buildErr := range buildErrs {
+		failed = append(failed, p.relSourcePath(buildErr.Path))
+	}
+	slices.Sort(failed)
+	if expected := []string{"guide/index.html", "setup.html", "static/css/main.css"}; !slices.Equal(failed, expected) {
+		t.Errorf("Expected the conflicting assets %v to fail, but got %v", expected, failed)
+	}
+
+	// The pages and the templates win
+	for _, name := range []string{"setup.html", "guide/index.html", "static/css/main.css"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(name)))
+		if err != nil || string(content) == "copied" {
+			t.Errorf("Expected '%s' not to be overwritten by the asset, but got %v", name, err)
+		}
+	}
+
+	// Other files are copied as usual
+	for name, expected := range map[string]string{"static/img/logo.png": "png", "about.html": "copied"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(name)))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected '%s' to be copied, but got '%s' and %v", name, content, err)
+		}
+	}
+}
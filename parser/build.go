@@ -0,0 +1,252 @@
+package parser
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// buildPlan holds the directories, pages and assets found under a root, in
+// the order they were discovered
+type buildPlan struct {
+	dirs   []string
+	pages  []string
+	assets []string
+}
+
+// job is a unit of work for the worker pool, identified by the path it
+// generates output for
+type job struct {
+	path string
+	run  func() error
+}
+
+// discover walks the tree under root and collects the directories, pages and
+// assets that make up the site. Unless failing fast, unreadable directories are
+// recorded as errors and skipped.
+func (p *Parser) discover(root string) (*buildPlan, BuildErrors, error) {
+	plan := &buildPlan{}
+	var errs BuildErrors
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p.FailFast || path == root {
+				return err
+			}
+
+			errs = append(errs, &BuildError{Path: path, Stage: StageRead, Err: err})
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			// Skip the output directory, and unless it is the root
+			// directory, we skip directories that are ignored
+			if p.isOutputPath(path) || (path != p.RootPath && p.isIgnored(d.Name())) {
+				return filepath.SkipDir
+			}
+
+			if !p.isAllowed(path, true) {
+				return filepath.SkipDir
+			}
+
+			plan.dirs = append(plan.dirs, path)
+			return nil
+		}
+
+		if !p.isAllowed(path, false) {
+			return nil
+		}
+
+		if p.isAsset(path) {
+			plan.assets = append(plan.assets, path)
+			return nil
+		}
+
+		// Index pages are rendered as part of their directory
+		if !p.isMarkdownFile(d.Name()) || p.isIgnored(d.Name()) || p.isIndexPage(d.Name()) {
+			return nil
+		}
+
+		plan.pages = append(plan.pages, path)
+		return nil
+	})
+
+	return plan, errs, err
+}
+
+// generateTree generates the indexes and pages of a directory and its
+// subdirectories. The listing of every directory is computed once and shared
+// by its pages, after which all pages are rendered and all assets are copied
+// by the worker pool.
+func (p *Parser) generateTree(root string) error {
+	plan, errs, err := p.discover(root)
+	if err != nil {
+		return BuildErrors{asBuildError(root, StageRead, err)}
+	}
+
+	listingErrs := p.readListings(plan.dirs)
+	errs = append(errs, listingErrs...)
+
+	if len(errs) > 0 && p.FailFast {
+		return errs
+	}
+
+	// Pages of directories without a listing can't be rendered
+	failed := make(map[string]bool)
+	for _, err := range listingErrs {
+		failed[err.Path] = true
+	}
+
+	var renderJobs []job
+	for _, dir := range plan.dirs {
+		if failed[dir] {
+			continue
+		}
+
+		renderJobs = append(renderJobs, job{
+			path: dir,
+			run: func() error {
+				files, _ := p.listing(dir)
+				return p.ensureIndexForDir(dir, files)
+			},
+		})
+	}
+
+	for _, path := range plan.pages {
+		if failed[filepath.Dir(path)] {
+			continue
+		}
+
+		renderJobs = append(renderJobs, job{
+			path: path,
+			run: func() error {
+				return p.generatePage(path)
+			},
+		})
+	}
+
+	// Assets don't depend on listings
+	for _, path := range plan.assets {
+		renderJobs = append(renderJobs, job{
+			path: path,
+			run: func() error {
+				return p.copyAsset(path)
+			},
+		})
+	}
+
+	errs = append(errs, p.runJobs(renderJobs)...)
+	return errs.orNil()
+}
+
+// readListings reads the listings of the directories on the worker pool, and
+// builds the navigation from them
+func (p *Parser) readListings(dirs []string) BuildErrors {
+	var jobs []job
+	for _, dir := range dirs {
+		jobs = append(jobs, job{
+			path: dir,
+			run: func() error {
+				files, err := p.getDirectoryListing(dir)
+				if err != nil {
+					return stageError(StageListing, err)
+				}
+				p.setListing(dir, files)
+				return nil
+			},
+		})
+	}
+
+	errs := p.runJobs(jobs)
+	p.updateNav()
+	return errs
+}
+
+// runJobs runs the jobs on a pool of p.Jobs workers and returns the errors in
+// the order of the jobs, so the result doesn't depend on scheduling. When
+// failing fast, no new jobs are started after the first error.
+func (p *Parser) runJobs(jobs []job) BuildErrors {
+	results := make([]error, len(jobs))
+
+	workers := min(max(p.Jobs, 1), len(jobs))
+	indexes := make(chan int)
+
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if p.FailFast && failed.Load() {
+					continue
+				}
+
+				if results[i] = jobs[i].run(); results[i] != nil {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+
+	for i := range jobs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs BuildErrors
+	for i, err := range results {
+		if err != nil {
+			errs = append(errs, asBuildError(jobs[i].path, "", err))
+		}
+	}
+
+	return errs
+}
+
+// asBuildError makes sure err is a BuildError for path, keeping the stage if
+// err already has one
+func asBuildError(path string, stage Stage, err error) *BuildError {
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		if buildErr.Path == "" {
+			buildErr.Path = path
+		}
+		return buildErr
+	}
+
+	return &BuildError{Path: path, Stage: stage, Err: err}
+}
+
+func (p *Parser) listing(dir string) ([]FileEntry, bool) {
+	p.listingsMu.RLock()
+	defer p.listingsMu.RUnlock()
+
+	files, ok := p.listings[dir]
+	return files, ok
+}
+
+func (p *Parser) setListing(dir string, files []FileEntry) {
+	p.listingsMu.Lock()
+	defer p.listingsMu.Unlock()
+
+	p.listings[dir] = files
+}
+
+// forgetListings removes the listings of a directory and its subdirectories
+func (p *Parser) forgetListings(dir string) {
+	p.listingsMu.Lock()
+	defer p.listingsMu.Unlock()
+
+	for path := range p.listings {
+		if isWithin(path, []string{dir}) {
+			delete(p.listings, path)
+		}
+	}
+}
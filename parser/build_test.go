@@ -0,0 +1,197 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type countingParser struct {
+	*GoldmarkParser
+	metadataCalls atomic.Int64
+}
+
+func (c *countingParser) ExtractMetadata(markdown []byte) (Page, []byte, error) {
+	c.metadataCalls.Add(1)
+	return c.GoldmarkParser.ExtractMetadata(markdown)
+}
+
+func TestGenerateParallelIsDeterministic(t *testing.T) {
+	rootDir := t.TempDir()
+
+	for _, dir := range []string{"", "a", "a/b", "c"} {
+		if err := os.MkdirAll(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+		for i := range 10 {
+			writeFile(t, filepath.Join(rootDir, dir, fmt.Sprintf("page-%d.md", i)), fmt.Sprintf("# Page %d\n\n## Section", i))
+		}
+	}
+
+	sequential := t.TempDir()
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(sequential), WithJobs(1))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	parallel := t.TempDir()
+	p = New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(parallel), WithJobs(8))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	err := filepath.WalkDir(sequential, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+
+		relPath, err := filepath.Rel(sequential, path)
+		if err != nil {
+			return err
+		}
+
+		expected, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		actual, err := os.ReadFile(filepath.Join(parallel, relPath))
+		if err != nil {
+			return err
+		}
+
+		if string(expected) != string(actual) {
+			t.Errorf("Expected '%s' to be the same for sequential and parallel builds", relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateSharesListings(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	const pages = 20
+	for i := range pages {
+		writeFile(t, filepath.Join(rootDir, fmt.Sprintf("page-%d.md", i)), "# Page")
+	}
+
+	md := &countingParser{GoldmarkParser: NewGoldmarkParser()}
+	p := New(md, WithRootPath(rootDir), WithOutputPath(outputDir), WithJobs(4))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once for the listing and once for rendering every page
+	if calls := md.metadataCalls.Load(); calls != 2*pages {
+		t.Errorf("Expected %d metadata extractions, but got %d", 2*pages, calls)
+	}
+}
+
+func TestGenerateCollectsPageErrors(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "broken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "good.md"), "# Good")
+	writeFile(t, filepath.Join(rootDir, "broken", "bad.md"), "---\ntitle: [unclosed\n---\n# Bad")
+	writeFile(t, filepath.Join(rootDir, "broken", "other.md"), "# Other")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithJobs(4))
+	err := p.Generate()
+	if err == nil {
+		t.Fatal("Expected an error, but got nil")
+	}
+
+	var buildErr *BuildError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("Expected a BuildError, but got %T", err)
+	}
+	if !strings.Contains(err.Error(), "bad.md") {
+		t.Errorf("Expected error to mention 'bad.md', but got '%v'", err)
+	}
+
+	// Pages outside the broken directory are still generated
+	if _, err := os.Stat(filepath.Join(outputDir, "good.html")); err != nil {
+		t.Errorf("Expected 'good.html' to be generated: %v", err)
+	}
+}
+
+type failingParser struct {
+	*GoldmarkParser
+}
+
+func (f *failingParser) Convert(markdown []byte) ([]byte, error) {
+	if strings.Contains(string(markdown), "fail") {
+		return nil, errors.New("conversion failed")
+	}
+	return f.GoldmarkParser.Convert(markdown)
+}
+
+func TestGenerateReportsAllFailures(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# fail")
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# Good")
+	writeFile(t, filepath.Join(rootDir, "c.md"), "# fail")
+
+	p := New(&failingParser{NewGoldmarkParser()}, WithRootPath(rootDir), WithOutputPath(outputDir), WithJobs(4))
+	err := p.Generate()
+
+	var buildErrs BuildErrors
+	if !errors.As(err, &buildErrs) {
+		t.Fatalf("Expected BuildErrors, but got %T", err)
+	}
+
+	if len(buildErrs) != 2 {
+		t.Fatalf("Expected 2 errors, but got %d: %v", len(buildErrs), err)
+	}
+
+	for i, name := range []string{"a.md", "c.md"} {
+		if buildErrs[i].Path != filepath.Join(rootDir, name) {
+			t.Errorf("Expected error %d for '%s', but got '%s'", i, name, buildErrs[i].Path)
+		}
+		if buildErrs[i].Stage != StageConvert {
+			t.Errorf("Expected stage '%s', but got '%s'", StageConvert, buildErrs[i].Stage)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "b.html")); err != nil {
+		t.Errorf("Expected 'b.html' to be generated: %v", err)
+	}
+}
+
+func TestGenerateFailFast(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# fail")
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# fail")
+	writeFile(t, filepath.Join(rootDir, "c.md"), "# Good")
+
+	p := New(&failingParser{NewGoldmarkParser()}, WithRootPath(rootDir), WithOutputPath(outputDir), WithJobs(1), WithFailFast(true))
+	err := p.Generate()
+
+	var buildErrs BuildErrors
+	if !errors.As(err, &buildErrs) {
+		t.Fatalf("Expected BuildErrors, but got %T", err)
+	}
+
+	if len(buildErrs) != 1 {
+		t.Errorf("Expected 1 error, but got %d: %v", len(buildErrs), err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "c.html")); err == nil {
+		t.Error("Expected 'c.html' not to be generated after the first failure")
+	}
+}
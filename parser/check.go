@@ -0,0 +1,290 @@
+package parser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// IssueKind is the kind of problem found by Check
+type IssueKind string
+
+const (
+	IssueInvalidLink   IssueKind = "invalid-link"
+	IssueMissingPage   IssueKind = "missing-page"
+	IssueMissingAnchor IssueKind = "missing-anchor"
+	IssueMissingAsset  IssueKind = "missing-asset"
+	IssueExternalLink  IssueKind = "external-link"
+)
+
+// Issue is a broken link in a page
+type Issue struct {
+	// Path is the markdown file relative to the root path
+	Path    string    `json:"path"`
+	Line    int       `json:"line"`
+	Link    string    `json:"link"`
+	Kind    IssueKind `json:"kind"`
+	Message string    `json:"message"`
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", i.Path, i.Line, i.Kind, i.Message)
+}
+
+// CheckReport is the result of checking the links of all pages
+type CheckReport struct {
+	Pages  int     `json:"pages"`
+	Links  int     `json:"links"`
+	Issues []Issue `json:"issues"`
+}
+
+// site is the model of the generated site that links are checked against,
+// keyed by source path
+type site struct {
+	pages       map[string]*checkedPage
+	unpublished map[string]bool
+	dirs        map[string]bool
+	assets      map[string]bool
+}
+
+type checkedPage struct {
+	links   []Link
+	anchors map[string]bool
+}
+
+// Check reports links in the published pages that point at pages, headings
+// or assets that are not part of the site. External links are only checked
+// against the allowlist, when one is set, and never requested.
+func (p *Parser) Check() (*CheckReport, error) {
+	extractor, ok := p.Parser.(LinkExtractor)
+	if !ok {
+		return nil, errors.New("the markdown parser doesn't support checking links")
+	}
+
+	plan, errs, err := p.discover(p.RootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &site{
+		pages:       make(map[string]*checkedPage),
+		unpublished: make(map[string]bool),
+		dirs:        make(map[string]bool),
+		assets:      make(map[string]bool),
+	}
+
+	sources := slices.Clone(plan.pages)
+	for _, dir := range plan.dirs {
+		s.dirs[dir] = true
+		if indexPath, ok := p.indexPageFor(dir); ok {
+			sources = append(sources, indexPath)
+		}
+	}
+	for _, asset := range plan.assets {
+		s.assets[asset] = true
+	}
+
+	for _, source := range sources {
+		page, published, err := p.readCheckedPage(extractor, source)
+		if err != nil {
+			errs = append(errs, asBuildError(source, "", err))
+			continue
+		}
+
+		if !published {
+			s.unpublished[source] = true
+			continue
+		}
+		s.pages[source] = page
+	}
+
+	report := &CheckReport{Issues: []Issue{}}
+	for _, source := range sortedKeys(s.pages) {
+		report.Pages++
+		for _, link := range s.pages[source].links {
+			report.Links++
+			if issue := p.checkLink(s, source, link); issue != nil {
+				issue.Path = p.relSourcePath(source)
+				issue.Line = link.Line
+				issue.Link = link.Destination
+				report.Issues = append(report.Issues, *issue)
+			}
+		}
+	}
+
+	return report, errs.orNil()
+}
+
+// readCheckedPage reads the links and heading anchors of a page
+func (p *Parser) readCheckedPage(extractor LinkExtractor, path string) (*checkedPage, bool, error) {
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, stageError(StageRead, err)
+	}
+
+	page, body, err := p.extractMetadata(markdown)
+	if err != nil {
+		return nil, false, stageError(StageMetadata, err)
+	}
+
+	if !p.isPublished(page) {
+		return nil, false, nil
+	}
+
+	links, err := extractor.ExtractLinks(body)
+	if err != nil {
+		return nil, false, stageError(StageConvert, err)
+	}
+
+	// Lines are counted from the start of the file, including front matter
+	if len(body) <= len(markdown) {
+		offset := strings.Count(string(markdown[:len(markdown)-len(body)]), "\n")
+		for i := range links {
+			links[i].Line += offset
+		}
+	}
+
+	toc, err := p.Parser.ExtractTOC(body)
+	if err != nil {
+		return nil, false, stageError(StageTOC, err)
+	}
+
+	anchors := make(map[string]bool, len(toc))
+	for _, entry := range toc {
+		anchors[entry.ID] = true
+	}
+
+	return &checkedPage{links: links, anchors: anchors}, true, nil
+}
+
+// checkLink returns the issue with a link in the page at source, if any
+func (p *Parser) checkLink(s *site, source string, link Link) *Issue {
+	u, err := url.Parse(link.Destination)
+	if err != nil {
+		return &Issue{Kind: IssueInvalidLink, Message: fmt.Sprintf("invalid link %q: %v", link.Destination, err)}
+	}
+
+	if u.Scheme != "" || u.Host != "" {
+		if p.ExternalAllowlist != nil && (u.Scheme == "http" || u.Scheme == "https") && !isAllowedURL(u, p.ExternalAllowlist) {
+			return &Issue{Kind: IssueExternalLink, Message: fmt.Sprintf("external link %q is not in the allowlist", link.Destination)}
+		}
+		return nil
+	}
+
+	// A link to a heading on the same page
+	if u.Path == "" {
+		return p.checkAnchor(s.pages[source], source, u.Fragment)
+	}
+
+	var target string
+	if strings.HasPrefix(u.Path, "/") {
+		target = filepath.Join(p.RootPath, filepath.FromSlash(u.Path))
+	} else {
+		target = filepath.Join(filepath.Dir(source), filepath.FromSlash(u.Path))
+	}
+
+	if !isWithin(target, []string{p.RootPath}) {
+		return &Issue{Kind: IssueMissingPage, Message: fmt.Sprintf("%q points outside of the site", link.Destination)}
+	}
+
+	if s.dirs[target] {
+		indexPath, _ := p.indexPageFor(target)
+		if page, ok := s.pages[indexPath]; ok {
+			return p.checkAnchor(page, indexPath, u.Fragment)
+		}
+		if u.Fragment != "" {
+			return p.missingAnchor(target, u.Fragment)
+		}
+		return nil
+	}
+
+	if s.assets[target] {
+		return nil
+	}
+
+	// Pages are linked to by their source file, their route, or the
+	// generated HTML file
+	ext := path.Ext(u.Path)
+	var candidates []string
+	switch {
+	case hasMarkdownExt(u.Path):
+		candidates = []string{target}
+	case ext == "" || ext == ".html":
+		base := strings.TrimSuffix(target, ext)
+		candidates = []string{base + ".md", base + ".markdown", base + ".mkd"}
+	}
+
+	for _, candidate := range candidates {
+		if page, ok := s.pages[candidate]; ok {
+			return p.checkAnchor(page, candidate, u.Fragment)
+		}
+		if s.unpublished[candidate] {
+			return &Issue{Kind: IssueMissingPage, Message: fmt.Sprintf("%s is not published", p.relSourcePath(candidate))}
+		}
+	}
+
+	if link.Image || (ext != "" && ext != ".html" && !hasMarkdownExt(u.Path)) {
+		return &Issue{Kind: IssueMissingAsset, Message: fmt.Sprintf("%s doesn't exist", p.relSourcePath(target))}
+	}
+	return &Issue{Kind: IssueMissingPage, Message: fmt.Sprintf("%s doesn't exist", p.relSourcePath(target))}
+}
+
+// checkAnchor checks that the page has a heading for the fragment
+func (p *Parser) checkAnchor(page *checkedPage, path, fragment string) *Issue {
+	if fragment == "" || page.anchors[fragment] {
+		return nil
+	}
+	return p.missingAnchor(path, fragment)
+}
+
+func (p *Parser) missingAnchor(path, fragment string) *Issue {
+	return &Issue{Kind: IssueMissingAnchor, Message: fmt.Sprintf("%s has no heading %q", p.relSourcePath(path), fragment)}
+}
+
+// isAllowedURL reports whether the URL matches an entry of the allowlist. An
+// entry with a scheme is a prefix of allowed URLs, otherwise it is a host
+// that is allowed including its subdomains.
+func isAllowedURL(u *url.URL, allowlist []string) bool {
+	host := u.Hostname()
+	for _, entry := range allowlist {
+		if strings.Contains(entry, "://") {
+			if strings.HasPrefix(u.String(), entry) {
+				return true
+			}
+			continue
+		}
+
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadAllowlist reads the allowed external URLs from a file, one per line.
+// Empty lines and lines starting with # are skipped.
+func ReadAllowlist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	allowlist := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		allowlist = append(allowlist, line)
+	}
+
+	return allowlist, scanner.Err()
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	rootDir := t.TempDir()
+
+	for _, dir := range []string{"guide", "img", "_private"} {
+		if err := os.Mkdir(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(t, filepath.Join(rootDir, "index.md"), `---
+title: Home
+---
+# Home
+
+[setup](guide/setup.md#install)
+[setup route](/guide/setup)
+[guide](guide/)
+[missing](guide/missing.md)
+[bad anchor](guide/setup.md#uninstall)
+[self](#home)
+[self missing](#nowhere)
+![diagram](img/arch.png)
+![missing image](img/missing.png)
+[private](_private/notes.md)
+[draft](draft.md)
+[external](https://example.com/page)
+[allowed](https://github.com/jpbruinsslot/mdex)
+`)
+	writeFile(t, filepath.Join(rootDir, "draft.md"), "---\ndraft: true\n---\n# Draft")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup\n\n## Install\n\n[home](../index.md#home)")
+	writeFile(t, filepath.Join(rootDir, "img", "arch.png"), "png")
+	writeFile(t, filepath.Join(rootDir, "_private", "notes.md"), "# Notes")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithExternalAllowlist([]string{"github.com"}))
+	report, err := p.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Pages != 2 {
+		t.Errorf("Expected 2 pages, but got %d", report.Pages)
+	}
+	if report.Links != 14 {
+		t.Errorf("Expected 14 links, but got %d", report.Links)
+	}
+
+	expected := []Issue{
+		{Path: "index.md", Line: 9, Link: "guide/missing.md", Kind: IssueMissingPage},
+		{Path: "index.md", Line: 10, Link: "guide/setup.md#uninstall", Kind: IssueMissingAnchor},
+		{Path: "index.md", Line: 12, Link: "#nowhere", Kind: IssueMissingAnchor},
+		{Path: "index.md", Line: 14, Link: "img/missing.png", Kind: IssueMissingAsset},
+		{Path: "index.md", Line: 15, Link: "_private/notes.md", Kind: IssueMissingPage},
+		{Path: "index.md", Line: 16, Link: "draft.md", Kind: IssueMissingPage},
+		{Path: "index.md", Line: 17, Link: "https://example.com/page", Kind: IssueExternalLink},
+	}
+
+	if len(report.Issues) != len(expected) {
+		t.Fatalf("Expected %d issues, but got %d: %v", len(expected), len(report.Issues), report.Issues)
+	}
+
+	for i, issue := range report.Issues {
+		issue.Message = ""
+		if issue != expected[i] {
+			t.Errorf("Expected issue %+v, but got %+v", expected[i], issue)
+		}
+	}
+}
+
+func TestCheckExternalWithoutAllowlist(t *testing.T) {
+	rootDir := t.TempDir()
+	writeFile(t, filepath.Join(rootDir, "page.md"), "[external](https://example.com) and https://example.org")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir))
+	report, err := p.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Issues) != 0 {
+		t.Errorf("Expected external links not to be checked, but got %v", report.Issues)
+	}
+}
+
+func TestIsAllowedURL(t *testing.T) {
+	allowlist := []string{"github.com", "https://example.com/docs/"}
+
+	tests := []struct {
+		url     string
+		allowed bool
+	}{
+		{"https://github.com/jpbruinsslot/mdex", true},
+		{"https://gist.github.com/abc", true},
+		{"https://notgithub.com", false},
+		{"https://example.com/docs/page", true},
+		{"https://example.com/blog", false},
+	}
+
+	for _, tt := range tests {
+		u := mustParseURL(t, tt.url)
+		if actual := isAllowedURL(u, allowlist); actual != tt.allowed {
+			t.Errorf("Expected '%s' allowed to be %v, but got %v", tt.url, tt.allowed, actual)
+		}
+	}
+}
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
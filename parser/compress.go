@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// minCompressSize is the size below which gzip saves too little to be worth
+// it
+const minCompressSize = 1024
+
+// compressedExts are the extensions of the outputs that compress well
+var compressedExts = map[string]bool{
+	".html": true,
+	".css":  true,
+	".js":   true,
+	".json": true,
+	".svg":  true,
+	".xml":  true,
+	".txt":  true,
+	".csv":  true,
+}
+
+// precompress writes a gzip compressed .gz sibling of every output that
+// compresses well, so mdex serve doesn't have to compress it on every
+// request. Siblings that are up to date are left as they are, and those of
+// removed outputs are removed. The siblings are recorded in the manifest, so
+// .gz files of other origins, like copied assets, are never touched.
+func (p *Parser) precompress() error {
+	for _, key := range p.manifest.keys() {
+		if entry, _ := p.manifest.get(key); !entry.Precompressed {
+			continue
+		}
+
+		path := filepath.Join(p.OutputPath, filepath.FromSlash(key))
+		original := strings.TrimSuffix(path, ".gz")
+		if info, err := os.Stat(original); err == nil && shouldCompress(original, info) {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		p.manifest.remove(key)
+	}
+
+	var jobs []job
+	err := filepath.WalkDir(p.OutputPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Hidden files, like the build manifest, are never served
+		if strings.HasPrefix(d.Name(), ".") && path != p.OutputPath {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !shouldCompress(path, info) {
+			return nil
+		}
+
+		gzPath := path + ".gz"
+		key, err := p.manifestKey(gzPath)
+		if err != nil {
+			return err
+		}
+		if entry, ok := p.manifest.get(key); ok {
+			// An output of its own, like a copied asset
+			if !entry.Precompressed {
+				return nil
+			}
+			if gzInfo, err := os.Stat(gzPath); err == nil && !gzInfo.ModTime().Before(info.ModTime()) {
+				return nil
+			}
+		}
+
+		jobs = append(jobs, job{
+			path: path,
+			run: func() error {
+				if err := compressFile(path); err != nil {
+					return stageError(StageSave, err)
+				}
+				p.record(gzPath, ManifestEntry{Precompressed: true})
+				return nil
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.runJobs(jobs).orNil()
+}
+
+// shouldCompress reports whether the output is worth compressing
+func shouldCompress(path string, info fs.FileInfo) bool {
+	return !info.IsDir() && info.Size() >= minCompressSize && compressedExts[filepath.Ext(path)]
+}
+
+// compressFile writes the gzip compressed contents of the file to its .gz
+// sibling
+func compressFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(content); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path+".gz", buf.Bytes(), 0644)
+}
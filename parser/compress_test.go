@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readGzip returns the decompressed contents of a .gz file
+func readGzip(t *testing.T, path string) []byte {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestGenerate_Precompress(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := filepath.Join(rootDir, "public")
+	writeFile(t, filepath.Join(rootDir, "index.md"), "# Home")
+	writeFile(t, filepath.Join(rootDir, "setup.md"), "# Setup\n\nRun make.")
+	writeFile(t, filepath.Join(rootDir, "small.txt"), "too small to compress")
+	// Assets that are compressed already, or come with their own .gz
+	writeFile(t, filepath.Join(rootDir, "archive.tar.gz"), "archive")
+	writeFile(t, filepath.Join(rootDir, "data.csv"), strings.Repeat("a,b\n", 500))
+	writeFile(t, filepath.Join(rootDir, "data.csv.gz"), "shipped")
+
+	generate := func(precompress bool) {
+		t.Helper()
+		p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithPrecompress(precompress))
+		if err := p.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	generate(true)
+
+	for _, file := range []string{"index.html", "setup.html", "static/css/main.css"} {
+		path := filepath.Join(outputDir, filepath.FromSlash(file))
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(readGzip(t, path+".gz"), content) {
+			t.Errorf("Expected %s.gz to hold the contents of %s", file, file)
+		}
+	}
+
+	for _, file := range []string{"small.txt.gz", ManifestFile + ".gz"} {
+		if _, err := os.Stat(filepath.Join(outputDir, file)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be written, but got %v", file, err)
+		}
+	}
+
+	// Siblings of removed pages are removed as well, .gz files that weren't
+	// written by precompress never are
+	for _, file := range []string{"setup.md", "data.csv"} {
+		if err := os.Remove(filepath.Join(rootDir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	generate(true)
+
+	for file, expected := range map[string]string{"archive.tar.gz": "archive", "data.csv.gz": "shipped"} {
+		content, err := os.ReadFile(filepath.Join(outputDir, file))
+		if err != nil || string(content) != expected {
+			t.Errorf("Expected %s to be kept as it is, but got '%s' and %v", file, content, err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "setup.html.gz")); !os.IsNotExist(err) {
+		t.Errorf("Expected setup.html.gz to be removed with its page, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html.gz")); err != nil {
+		t.Errorf("Expected index.html.gz to be kept, but got %v", err)
+	}
+
+	// Siblings are removed once they are no longer written
+	generate(false)
+	if _, err := os.Stat(filepath.Join(outputDir, "index.html.gz")); !os.IsNotExist(err) {
+		t.Errorf("Expected index.html.gz to be removed without precompress, but got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, "archive.tar.gz")); err != nil {
+		t.Errorf("Expected archive.tar.gz to be kept, but got %v", err)
+	}
+}
+
+func TestGenerate_PrecompressSearch(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := filepath.Join(rootDir, "public")
+	var page strings.Builder
+	page.WriteString("# Home\n")
+	for i := range 100 {
+		fmt.Fprintf(&page, "\n## Step %d of the platform setup\n", i)
+	}
+	writeFile(t, filepath.Join(rootDir, "index.md"), page.String())
+
+	generate := func() {
+		t.Helper()
+		p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithPrecompress(true))
+		if err := p.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	generate()
+
+	gzPath := filepath.Join(outputDir, SearchDir, "docs", "0.json.gz")
+	if _, err := os.Stat(gzPath); err != nil {
+		t.Fatalf("Expected the search index to be compressed, but got %v", err)
+	}
+
+	// Unchanged files of the index aren't compressed again
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(strings.TrimSuffix(gzPath, ".gz"), old.Add(-time.Minute), old.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(gzPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	generate()
+
+	info, err := os.Stat(gzPath)
+	if err != nil {
+		t.Fatalf("Expected the compressed search index to be kept, but got %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("Expected the compressed search index not to be written again, but got %v", info.ModTime())
+	}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Stage is the step of generating an output
+type Stage string
+
+const (
+	StageRead     Stage = "read"
+	StageMetadata Stage = "metadata"
+	StageListing  Stage = "listing"
+	StageConvert  Stage = "convert"
+	StageTOC      Stage = "toc"
+	StageRender   Stage = "render"
+	StageSearch   Stage = "search"
+	StageSave     Stage = "save"
+)
+
+// BuildError is the failure to generate the output of a single source file or
+// directory
+type BuildError struct {
+	Path  string
+	Stage Stage
+	Err   error
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Path, e.Stage, e.Err)
+}
+
+func (e *BuildError) Unwrap() error {
+	return e.Err
+}
+
+// BuildErrors holds all errors of a build, in the order of the source files
+type BuildErrors []*BuildError
+
+func (e BuildErrors) Error() string {
+	var b strings.Builder
+
+	if len(e) == 1 {
+		b.WriteString("1 file failed to build:")
+	} else {
+		fmt.Fprintf(&b, "%d files failed to build:", len(e))
+	}
+
+	for _, err := range e {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
+	}
+
+	return b.String()
+}
+
+func (e BuildErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// orNil returns nil when there are no errors, so an empty BuildErrors doesn't
+// end up as a non-nil error interface
+func (e BuildErrors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// appendBuildErrors adds err, which can be a BuildErrors, to errs
+func appendBuildErrors(errs BuildErrors, path string, err error) BuildErrors {
+	var buildErrs BuildErrors
+	if errors.As(err, &buildErrs) {
+		return append(errs, buildErrs...)
+	}
+	return append(errs, asBuildError(path, "", err))
+}
+
+// stageError wraps an error with the stage it occurred in, the path is filled
+// in by the caller that knows which output is being generated
+func stageError(stage Stage, err error) error {
+	return &BuildError{Stage: stage, Err: err}
+}
@@ -0,0 +1,302 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Page holds the metadata of a markdown page, as declared in its front matter.
+// Well known keys are available as typed fields, every key (including the
+// well known ones) is available in Params.
+type Page struct {
+	Title       string
+	Description string
+	Date        time.Time
+	PublishDate time.Time
+	ExpiryDate  time.Time
+	Draft       bool
+	Weight      int
+	Tags        []string
+	Params      map[string]any
+}
+
+// MetadataExtractor can be implemented by a MarkdownParser to report the
+// metadata of a page itself. It returns the metadata and the markdown with
+// the metadata block removed. Parsers that don't implement it get the
+// default YAML/TOML front matter handling from ParseFrontMatter.
+type MetadataExtractor interface {
+	ExtractMetadata(markdown []byte) (Page, []byte, error)
+}
+
+var (
+	yamlDelimiter = []byte("---")
+	tomlDelimiter = []byte("+++")
+)
+
+// ParseFrontMatter parses a leading YAML (---) or TOML (+++) front matter
+// block and returns the page metadata and the remaining markdown. When no
+// front matter is present the markdown is returned untouched.
+func ParseFrontMatter(markdown []byte) (Page, []byte, error) {
+	page := Page{Params: map[string]any{}}
+
+	source := bytes.TrimPrefix(markdown, []byte("\ufeff"))
+
+	var delimiter []byte
+	switch {
+	case hasDelimiterLine(source, yamlDelimiter):
+		delimiter = yamlDelimiter
+	case hasDelimiterLine(source, tomlDelimiter):
+		delimiter = tomlDelimiter
+	default:
+		return page, markdown, nil
+	}
+
+	// Skip the opening delimiter line and look for the closing one
+	start := bytes.IndexByte(source, '\n') + 1
+	block, body, ok := splitFrontMatter(source[start:], delimiter)
+	if !ok {
+		// Without a closing delimiter this is not front matter, a leading
+		// --- is a valid thematic break in markdown.
+		return page, markdown, nil
+	}
+
+	var err error
+	if bytes.Equal(delimiter, yamlDelimiter) {
+		err = yaml.Unmarshal(block, &page.Params)
+	} else {
+		err = toml.Unmarshal(block, &page.Params)
+	}
+	if err != nil {
+		return page, markdown, fmt.Errorf("failed to parse front matter: %w", err)
+	}
+
+	if page.Params == nil {
+		page.Params = map[string]any{}
+	}
+
+	if err := page.decodeParams(); err != nil {
+		return page, markdown, err
+	}
+
+	return page, body, nil
+}
+
+// hasDelimiterLine reports whether the first line of source consists of only
+// the delimiter.
+func hasDelimiterLine(source, delimiter []byte) bool {
+	line, _, _ := bytes.Cut(source, []byte("\n"))
+	return bytes.Equal(bytes.TrimRight(line, " \t\r"), delimiter)
+}
+
+// splitFrontMatter splits source at the first line consisting of only the
+// delimiter, and returns the content before and after that line.
+func splitFrontMatter(source, delimiter []byte) ([]byte, []byte, bool) {
+	offset := 0
+	for offset < len(source) {
+		end := bytes.IndexByte(source[offset:], '\n')
+		next := len(source)
+		if end >= 0 {
+			next = offset + end + 1
+		}
+
+		line := bytes.TrimRight(source[offset:next], " \t\r\n")
+		if bytes.Equal(line, delimiter) {
+			return source[:offset], source[next:], true
+		}
+
+		offset = next
+	}
+
+	return nil, nil, false
+}
+
+// IsPublished reports whether the page is visible at the given time: it is
+// not a draft, its publish date has passed and it has not expired yet.
+func (pg Page) IsPublished(now time.Time, drafts, future bool) bool {
+	if pg.Draft && !drafts {
+		return false
+	}
+	if !pg.PublishDate.IsZero() && pg.PublishDate.After(now) && !future {
+		return false
+	}
+	if !pg.ExpiryDate.IsZero() && !pg.ExpiryDate.After(now) {
+		return false
+	}
+	return true
+}
+
+// decodeParams fills the typed fields of the page from its params
+func (pg *Page) decodeParams() error {
+	var err error
+
+	if pg.Title, err = stringParam(pg.Params, "title"); err != nil {
+		return err
+	}
+	if pg.Description, err = stringParam(pg.Params, "description"); err != nil {
+		return err
+	}
+	if pg.Date, err = timeParam(pg.Params, "date"); err != nil {
+		return err
+	}
+	if pg.PublishDate, err = timeParam(pg.Params, "publishDate"); err != nil {
+		return err
+	}
+	if pg.ExpiryDate, err = timeParam(pg.Params, "expiryDate"); err != nil {
+		return err
+	}
+	if pg.Draft, err = boolParam(pg.Params, "draft"); err != nil {
+		return err
+	}
+	if pg.Weight, err = intParam(pg.Params, "weight"); err != nil {
+		return err
+	}
+	if pg.Tags, err = stringsParam(pg.Params, "tags"); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// lookupParam finds a param by key, ignoring case
+func lookupParam(params map[string]any, key string) (any, bool) {
+	if v, ok := params[key]; ok {
+		return v, true
+	}
+	for k, v := range params {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func stringParam(params map[string]any, key string) (string, error) {
+	v, ok := lookupParam(params, key)
+	if !ok || v == nil {
+		return "", nil
+	}
+
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(s), nil
+	default:
+		return "", fmt.Errorf("front matter %q must be a string, got %T", key, v)
+	}
+}
+
+func boolParam(params map[string]any, key string) (bool, error) {
+	v, ok := lookupParam(params, key)
+	if !ok || v == nil {
+		return false, nil
+	}
+
+	switch b := v.(type) {
+	case bool:
+		return b, nil
+	case string:
+		parsed, err := strconv.ParseBool(b)
+		if err != nil {
+			return false, fmt.Errorf("front matter %q must be a boolean: %w", key, err)
+		}
+		return parsed, nil
+	default:
+		return false, fmt.Errorf("front matter %q must be a boolean, got %T", key, v)
+	}
+}
+
+func intParam(params map[string]any, key string) (int, error) {
+	v, ok := lookupParam(params, key)
+	if !ok || v == nil {
+		return 0, nil
+	}
+
+	switch n := v.(type) {
+	case int:
+		return n, nil
+	case int64:
+		return int(n), nil
+	case uint64:
+		return int(n), nil
+	case float64:
+		return int(n), nil
+	case string:
+		parsed, err := strconv.Atoi(n)
+		if err != nil {
+			return 0, fmt.Errorf("front matter %q must be an integer: %w", key, err)
+		}
+		return parsed, nil
+	default:
+		return 0, fmt.Errorf("front matter %q must be an integer, got %T", key, v)
+	}
+}
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func timeParam(params map[string]any, key string) (time.Time, error) {
+	v, ok := lookupParam(params, key)
+	if !ok || v == nil {
+		return time.Time{}, nil
+	}
+
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		for _, layout := range timeLayouts {
+			if parsed, err := time.ParseInLocation(layout, strings.TrimSpace(t), time.Local); err == nil {
+				return parsed, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("front matter %q has an unsupported date format: %q", key, t)
+	default:
+		return time.Time{}, fmt.Errorf("front matter %q must be a date, got %T", key, v)
+	}
+}
+
+func stringsParam(params map[string]any, key string) ([]string, error) {
+	v, ok := lookupParam(params, key)
+	if !ok || v == nil {
+		return nil, nil
+	}
+
+	switch list := v.(type) {
+	case string:
+		// Allow a comma separated list
+		var result []string
+		for _, s := range strings.Split(list, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				result = append(result, s)
+			}
+		}
+		return result, nil
+	case []string:
+		return list, nil
+	case []any:
+		result := make([]string, 0, len(list))
+		for _, item := range list {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("front matter %q must be a list of strings, got %T", key, item)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("front matter %q must be a list of strings, got %T", key, v)
+	}
+}
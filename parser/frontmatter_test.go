@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseFrontMatterYAML(t *testing.T) {
+	markdown := []byte(`---
+title: Getting Started
+description: How to get started
+date: 2024-03-01
+draft: true
+weight: 10
+tags: [setup, intro]
+author: jane
+---
+# Hello
+`)
+
+	page, body, err := ParseFrontMatter(markdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Title != "Getting Started" {
+		t.Errorf("Expected title to be 'Getting Started', but got '%s'", page.Title)
+	}
+	if page.Description != "How to get started" {
+		t.Errorf("Expected description to be 'How to get started', but got '%s'", page.Description)
+	}
+	if !page.Date.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Expected date to be 2024-03-01, but got %v", page.Date)
+	}
+	if !page.Draft {
+		t.Error("Expected page to be a draft")
+	}
+	if page.Weight != 10 {
+		t.Errorf("Expected weight to be 10, but got %d", page.Weight)
+	}
+	if !slices.Equal(page.Tags, []string{"setup", "intro"}) {
+		t.Errorf("Expected tags to be [setup intro], but got %v", page.Tags)
+	}
+	if page.Params["author"] != "jane" {
+		t.Errorf("Expected param author to be 'jane', but got '%v'", page.Params["author"])
+	}
+	if string(body) != "# Hello\n" {
+		t.Errorf("Expected front matter to be stripped, but got '%s'", body)
+	}
+}
+
+func TestParseFrontMatterTOML(t *testing.T) {
+	markdown := []byte(`+++
+title = "Reference"
+date = 2024-03-01T10:00:00Z
+weight = 3
+tags = ["api"]
++++
+# Reference
+`)
+
+	page, body, err := ParseFrontMatter(markdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Title != "Reference" {
+		t.Errorf("Expected title to be 'Reference', but got '%s'", page.Title)
+	}
+	if !page.Date.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected date to be 2024-03-01T10:00:00Z, but got %v", page.Date)
+	}
+	if page.Weight != 3 {
+		t.Errorf("Expected weight to be 3, but got %d", page.Weight)
+	}
+	if !slices.Equal(page.Tags, []string{"api"}) {
+		t.Errorf("Expected tags to be [api], but got %v", page.Tags)
+	}
+	if string(body) != "# Reference\n" {
+		t.Errorf("Expected front matter to be stripped, but got '%s'", body)
+	}
+}
+
+func TestParseFrontMatterWithout(t *testing.T) {
+	tests := []string{
+		"# No front matter\n",
+		"---\n\nA thematic break without a closing delimiter\n",
+		"",
+	}
+
+	for _, markdown := range tests {
+		page, body, err := ParseFrontMatter([]byte(markdown))
+		if err != nil {
+			t.Fatalf("Expected no error for %q, but got %v", markdown, err)
+		}
+		if string(body) != markdown {
+			t.Errorf("Expected markdown %q to be untouched, but got %q", markdown, body)
+		}
+		if page.Params == nil || len(page.Params) != 0 {
+			t.Errorf("Expected empty params for %q, but got %v", markdown, page.Params)
+		}
+	}
+}
+
+func TestParseFrontMatterInvalid(t *testing.T) {
+	tests := []string{
+		"---\ntitle: [unclosed\n---\n",
+		"---\nweight: heavy\n---\n",
+		"+++\ntitle = \n+++\n",
+	}
+
+	for _, markdown := range tests {
+		if _, _, err := ParseFrontMatter([]byte(markdown)); err == nil {
+			t.Errorf("Expected an error for %q, but got nil", markdown)
+		}
+	}
+}
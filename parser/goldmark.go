@@ -10,14 +10,45 @@ import (
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
+// GoldmarkParser converts markdown with goldmark. It is safe for concurrent
+// use, since it isn't changed after it is created.
 type GoldmarkParser struct {
 	mdParser goldmark.Markdown
+	// sourceParser parses links as they were written, for checking them
+	sourceParser parser.Parser
 }
 
 func NewGoldmarkParser() *GoldmarkParser {
-	mdParser := goldmark.New(
+	return newGoldmarkParser("")
+}
+
+func newGoldmarkParser(basePath string) *GoldmarkParser {
+	return &GoldmarkParser{
+		mdParser:     newGoldmarkWithLinks(basePath),
+		sourceParser: newGoldmark(parser.WithAutoHeadingID()).Parser(),
+	}
+}
+
+// WithBasePath returns a parser that prefixes site-relative links in the
+// converted markdown with the base path
+func (p *GoldmarkParser) WithBasePath(basePath string) MarkdownParser {
+	return newGoldmarkParser(basePath)
+}
+
+func newGoldmarkWithLinks(basePath string) goldmark.Markdown {
+	return newGoldmark(
+		parser.WithAutoHeadingID(),
+		parser.WithASTTransformers(
+			util.Prioritized(&linkTransformer{basePath: basePath}, 100),
+		),
+	)
+}
+
+func newGoldmark(opts ...parser.Option) goldmark.Markdown {
+	return goldmark.New(
 		goldmark.WithExtensions(
 			extension.Table,
 			extension.Strikethrough,
@@ -28,14 +59,8 @@ func NewGoldmarkParser() *GoldmarkParser {
 				highlighting.WithStyle("dracula"),
 			),
 		),
-		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
-		),
+		goldmark.WithParserOptions(opts...),
 	)
-
-	return &GoldmarkParser{
-		mdParser: mdParser,
-	}
 }
 
 func (p *GoldmarkParser) Convert(markdown []byte) ([]byte, error) {
@@ -47,6 +72,10 @@ func (p *GoldmarkParser) Convert(markdown []byte) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+func (p *GoldmarkParser) ExtractMetadata(markdown []byte) (Page, []byte, error) {
+	return ParseFrontMatter(markdown)
+}
+
 func (p *GoldmarkParser) ExtractTOC(markdown []byte) ([]TOCEntry, error) {
 	// Parse the markdown with the same parser configuration
 	reader := text.NewReader(markdown)
@@ -60,14 +89,7 @@ func (p *GoldmarkParser) ExtractTOC(markdown []byte) ([]TOCEntry, error) {
 		}
 
 		if heading, ok := n.(*ast.Heading); ok {
-			var title bytes.Buffer
-
-			// Extract text content from all child nodes
-			for c := heading.FirstChild(); c != nil; c = c.NextSibling() {
-				if textNode, ok := c.(*ast.Text); ok {
-					title.Write(textNode.Segment.Value(markdown))
-				}
-			}
+			title := headingText(heading, markdown)
 
 			// Get the heading ID - this will be auto-generated if WithAutoHeadingID() is used
 			var headingID string
@@ -77,15 +99,15 @@ func (p *GoldmarkParser) ExtractTOC(markdown []byte) ([]TOCEntry, error) {
 				} else if idBytes, ok := id.([]byte); ok {
 					headingID = string(idBytes)
 				} else {
-					headingID = generateID(title.String())
+					headingID = generateID(title)
 				}
 			} else {
 				// Fallback: generate ID from title if no auto-generated ID exists
-				headingID = generateID(title.String())
+				headingID = generateID(title)
 			}
 
 			toc = append(toc, TOCEntry{
-				Title: strings.TrimSpace(title.String()),
+				Title: strings.TrimSpace(title),
 				Level: heading.Level,
 				ID:    headingID,
 			})
@@ -101,6 +123,31 @@ func (p *GoldmarkParser) ExtractTOC(markdown []byte) ([]TOCEntry, error) {
 	return toc, nil
 }
 
+// headingText returns the text of a heading, including the text of its
+// emphasis, code spans and links
+func headingText(heading *ast.Heading, source []byte) string {
+	var text bytes.Buffer
+	_ = ast.Walk(heading, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Text:
+			text.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() {
+				text.WriteByte(' ')
+			}
+		case *ast.String:
+			text.Write(n.Value)
+		case *ast.AutoLink:
+			text.Write(n.Label(source))
+		}
+		return ast.WalkContinue, nil
+	})
+	return text.String()
+}
+
 func generateID(s string) string {
 	return strings.ReplaceAll(strings.ToLower(s), " ", "-")
 }
@@ -0,0 +1,166 @@
+package parser
+
+import (
+	"bytes"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// linkTransformer rewrites links to markdown files into the routes of the
+// pages generated for them, so links that work on GitHub also work in the
+// generated site. Site-relative links are prefixed with the base path.
+type linkTransformer struct {
+	basePath string
+}
+
+// BasePathParser is implemented by markdown parsers that prefix site-relative
+// links with the base path the site is hosted at. WithBasePath returns a
+// parser of its own, so parsers for other base paths or accesses never
+// change one that is in use.
+type BasePathParser interface {
+	WithBasePath(basePath string) MarkdownParser
+}
+
+func (t *linkTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Link:
+			n.Destination = []byte(prefixLink(rewriteLink(string(n.Destination)), t.basePath))
+		case *ast.Image:
+			n.Destination = []byte(prefixLink(string(n.Destination), t.basePath))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+// rewriteLink turns a link to a markdown file, like ../guide/setup.md#install,
+// into the route of its page, like ../guide/setup#install. External links and
+// links to other files are returned as they are.
+func rewriteLink(dest string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" {
+		return dest
+	}
+
+	// Keep the query and fragment exactly as they were written
+	target, suffix := dest, ""
+	if i := strings.IndexAny(dest, "?#"); i >= 0 {
+		target, suffix = dest[:i], dest[i:]
+	}
+
+	if !hasMarkdownExt(target) {
+		return dest
+	}
+
+	route := strings.TrimSuffix(target, path.Ext(target))
+
+	// Index pages are served as their directory
+	if name := path.Base(route); name == "index" {
+		route = strings.TrimSuffix(route, name)
+		if route == "" {
+			route = "./"
+		}
+	}
+
+	return route + suffix
+}
+
+// prefixLink adds the base path to site-relative links, like /guide/setup
+func prefixLink(dest, basePath string) string {
+	if basePath == "" || !strings.HasPrefix(dest, "/") || strings.HasPrefix(dest, "//") {
+		return dest
+	}
+	return basePath + dest
+}
+
+// Link is a link or image in a markdown document, as it was written
+type Link struct {
+	Destination string
+	// Line is the line of the link in the document, starting at 1
+	Line  int
+	Image bool
+}
+
+// LinkExtractor is implemented by markdown parsers that can list the links of
+// a document, which is needed to check them
+type LinkExtractor interface {
+	ExtractLinks(markdown []byte) ([]Link, error)
+}
+
+func (p *GoldmarkParser) ExtractLinks(markdown []byte) ([]Link, error) {
+	doc := p.sourceParser.Parse(text.NewReader(markdown))
+
+	var links []Link
+
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var link Link
+		switch n := n.(type) {
+		case *ast.Link:
+			link = Link{Destination: string(n.Destination)}
+		case *ast.Image:
+			link = Link{Destination: string(n.Destination), Image: true}
+		case *ast.AutoLink:
+			if n.AutoLinkType != ast.AutoLinkURL {
+				return ast.WalkContinue, nil
+			}
+			link = Link{Destination: string(n.URL(markdown))}
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		link.Line = bytes.Count(markdown[:linkOffset(n, link.Destination, markdown)], []byte("\n")) + 1
+		links = append(links, link)
+
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return links, nil
+}
+
+// linkOffset returns the position of a link in the source. Inline nodes don't
+// have a position, but the text inside of them has, otherwise the link is
+// looked up in the block that contains it.
+func linkOffset(n ast.Node, dest string, source []byte) int {
+	offset := -1
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); ok && entering {
+			offset = t.Segment.Start
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	if offset >= 0 {
+		return offset
+	}
+
+	for block := n.Parent(); block != nil; block = block.Parent() {
+		if block.Type() != ast.TypeBlock || block.Lines().Len() == 0 {
+			continue
+		}
+
+		start := block.Lines().At(0).Start
+		if i := bytes.Index(source[start:], []byte(dest)); i >= 0 {
+			return start + i
+		}
+		return start
+	}
+
+	return 0
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteLink(t *testing.T) {
+	tests := []struct {
+		dest     string
+		expected string
+	}{
+		{"setup.md", "setup"},
+		{"../guide/setup.md#install", "../guide/setup#install"},
+		{"guide/setup.markdown?tab=linux#install", "guide/setup?tab=linux#install"},
+		{"notes.mkd", "notes"},
+		{"/guide/setup.md", "/guide/setup"},
+		{"guide/index.md", "guide/"},
+		{"index.md#top", "./#top"},
+		{"/index.md", "/"},
+		{"#install", "#install"},
+		{"img/arch.png", "img/arch.png"},
+		{"guide/", "guide/"},
+		{"https://github.com/jpbruinsslot/mdex/blob/main/README.md", "https://github.com/jpbruinsslot/mdex/blob/main/README.md"},
+		{"//example.com/page.md", "//example.com/page.md"},
+		{"mailto:someone@example.com", "mailto:someone@example.com"},
+		{"readme.md.txt", "readme.md.txt"},
+	}
+
+	for _, tt := range tests {
+		if actual := rewriteLink(tt.dest); actual != tt.expected {
+			t.Errorf("Expected '%s' to be rewritten to '%s', but got '%s'", tt.dest, tt.expected, actual)
+		}
+	}
+}
+
+func TestConvertRewritesLinks(t *testing.T) {
+	p := NewGoldmarkParser()
+
+	html, err := p.Convert([]byte("[see setup](../guide/setup.md#install) and [GitHub](https://github.com/README.md)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedLinks := []string{
+		`<a href="../guide/setup#install">see setup</a>`,
+		`<a href="https://github.com/README.md">GitHub</a>`,
+	}
+	for _, expected := range expectedLinks {
+		if !strings.Contains(string(html), expected) {
+			t.Errorf("Expected HTML to contain '%s', but got '%s'", expected, html)
+		}
+	}
+}
+
+func TestExtractLinks(t *testing.T) {
+	p := NewGoldmarkParser()
+
+	markdown := "# Title\n\nSee [setup](setup.md#install)\nand ![diagram](img/arch.png).\n\nVisit https://example.com"
+	links, err := p.ExtractLinks([]byte(markdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Link{
+		{Destination: "setup.md#install", Line: 3},
+		{Destination: "img/arch.png", Line: 4, Image: true},
+		{Destination: "https://example.com", Line: 6},
+	}
+
+	if len(links) != len(expected) {
+		t.Fatalf("Expected %d links, but got %d: %v", len(expected), len(links), links)
+	}
+
+	for i, link := range links {
+		if link != expected[i] {
+			t.Errorf("Expected link %+v, but got %+v", expected[i], link)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/jpbruinsslot/mdex/templates"
+)
+
+// ManifestFile is the name of the build manifest in the output directory
+const ManifestFile = ".mdex-manifest.json"
+
+// manifestVersion is increased whenever the way outputs are generated changes
+// in a way that invalidates previous builds
+const manifestVersion = 2
+
+// Manifest records the inputs of every generated output, so a later build
+// can skip outputs whose inputs didn't change.
+type Manifest struct {
+	Version int `json:"version"`
+	// Templates is the hash of the templates and the options that affect
+	// the rendering of every output
+	Templates string `json:"templates"`
+	// Outputs are keyed by their path relative to the output directory
+	Outputs map[string]ManifestEntry `json:"outputs"`
+
+	mu sync.Mutex
+}
+
+// ManifestEntry holds the inputs an output was generated from
+type ManifestEntry struct {
+	// Source is the markdown file relative to the root path, if any
+	Source     string `json:"source,omitempty"`
+	SourceHash string `json:"sourceHash,omitempty"`
+	// Listing is the directory, relative to the root path, whose listing
+	// is rendered in the sidebar of the output
+	Listing     string `json:"listing"`
+	ListingHash string `json:"listingHash"`
+	// Precompressed is set for the .gz siblings written by precompress,
+	// which are the only .gz files it removes
+	Precompressed bool `json:"precompressed,omitempty"`
+}
+
+func newManifest(templateHash string) *Manifest {
+	return &Manifest{
+		Version:   manifestVersion,
+		Templates: templateHash,
+		Outputs:   make(map[string]ManifestEntry),
+	}
+}
+
+// loadManifest reads the manifest from the output directory. A missing,
+// unreadable or outdated manifest results in an empty one, so everything
+// is generated again.
+func loadManifest(outputPath, templateHash string) *Manifest {
+	m := newManifest(templateHash)
+
+	data, err := os.ReadFile(filepath.Join(outputPath, ManifestFile))
+	if err != nil {
+		return m
+	}
+
+	var previous Manifest
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return m
+	}
+
+	if previous.Version != manifestVersion || previous.Templates != templateHash || previous.Outputs == nil {
+		return m
+	}
+
+	m.Outputs = previous.Outputs
+	return m
+}
+
+func (m *Manifest) save(outputPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(outputPath, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(outputPath, ManifestFile), data, 0644)
+}
+
+func (m *Manifest) get(output string) (ManifestEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.Outputs[output]
+	return entry, ok
+}
+
+func (m *Manifest) set(output string, entry ManifestEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Outputs[output] = entry
+}
+
+// remove deletes the output and, when it is a directory, all outputs
+// inside of it
+func (m *Manifest) remove(output string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key := range m.Outputs {
+		if key == output || strings.HasPrefix(key, output+"/") {
+			delete(m.Outputs, key)
+		}
+	}
+}
+
+// carryOver adds the outputs of the previous manifest that are not part of
+// this one
+func (m *Manifest) carryOver(previous *Manifest) {
+	if previous == m {
+		return
+	}
+
+	for _, key := range previous.keys() {
+		entry, _ := previous.get(key)
+
+		m.mu.Lock()
+		if _, ok := m.Outputs[key]; !ok {
+			m.Outputs[key] = entry
+		}
+		m.mu.Unlock()
+	}
+}
+
+// keys returns the outputs in the manifest, sorted
+func (m *Manifest) keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.Outputs))
+	for key := range m.Outputs {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// isUpToDate reports whether the output exists and was generated from the
+// same inputs, in which case it is carried over to the current manifest.
+func (p *Parser) isUpToDate(outputPath string, entry ManifestEntry) bool {
+	if !p.Incremental {
+		return false
+	}
+
+	key, err := p.manifestKey(outputPath)
+	if err != nil {
+		return false
+	}
+
+	if previous, ok := p.previous.get(key); !ok || previous != entry {
+		return false
+	}
+
+	if _, err := os.Stat(outputPath); err != nil {
+		return false
+	}
+
+	p.manifest.set(key, entry)
+	return true
+}
+
+// record adds a generated output to the current manifest
+func (p *Parser) record(outputPath string, entry ManifestEntry) {
+	if key, err := p.manifestKey(outputPath); err == nil {
+		p.manifest.set(key, entry)
+	}
+}
+
+// forget removes an output from the current manifest
+func (p *Parser) forget(outputPath string) {
+	if key, err := p.manifestKey(outputPath); err == nil {
+		p.manifest.remove(key)
+	}
+}
+
+// removeStaleOutputs deletes outputs of the previous build that were not
+// generated by the current one, for example because their source was removed
+func (p *Parser) removeStaleOutputs() error {
+	for _, key := range p.previous.keys() {
+		if _, ok := p.manifest.get(key); ok {
+			continue
+		}
+
+		// Precompressed siblings are kept up to date by precompress
+		if entry, _ := p.previous.get(key); entry.Precompressed && p.Precompress {
+			p.manifest.set(key, entry)
+			continue
+		}
+
+		p.Logger.Info("Removing stale output", "file", key)
+		err := os.Remove(filepath.Join(p.OutputPath, filepath.FromSlash(key)))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) manifestKey(outputPath string) (string, error) {
+	relPath, err := filepath.Rel(p.OutputPath, outputPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+func (p *Parser) relSourcePath(path string) string {
+	relPath, err := filepath.Rel(p.RootPath, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// templateHash hashes the templates and the options that affect the
+// rendering of every output
+func (p *Parser) templateHash() (string, error) {
+	h := sha256.New()
+
+	err := fs.WalkDir(templates.FS, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		content, err := fs.ReadFile(templates.FS, path)
+		if err != nil {
+			return err
+		}
+
+		h.Write([]byte(path))
+		h.Write(content)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	settings, err := json.Marshal(struct {
+		LiveReload   bool
+		BasePath     string
+		FlatNav      bool
+		Search       bool
+		ServerSearch bool
+	}{
+		LiveReload:   p.LiveReload,
+		BasePath:     p.BasePath,
+		FlatNav:      p.FlatNav,
+		Search:       p.Search,
+		ServerSearch: p.ServerSearch,
+	})
+	if err != nil {
+		return "", err
+	}
+	h.Write(settings)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashSidebar hashes what is rendered in the sidebar of a page, which is the
+// listing of its directory and the navigation tree of the site
+func (p *Parser) hashSidebar(files []FileEntry) string {
+	if p.FlatNav {
+		return hashListing(files)
+	}
+	return hashBytes([]byte(hashListing(files) + p.navHash))
+}
+
+// hashNavigation hashes everything on a page besides its content: the
+// sidebar and the links to the pages around it
+func (p *Parser) hashNavigation(files []FileEntry, links PageLinks) string {
+	return hashBytes([]byte(p.hashSidebar(files) + hashPageLinks(links)))
+}
+
+func hashListing(files []FileEntry) string {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(data)
+}
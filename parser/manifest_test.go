@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGenerateIncremental(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# B")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+
+	generate := func(opts ...Option) {
+		t.Helper()
+		// With a flat sidebar, a new page only affects its own directory
+		opts = append([]Option{WithRootPath(rootDir), WithOutputPath(outputDir), WithFlatNav(true)}, opts...)
+		if err := New(NewGoldmarkParser(), opts...).Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	generate()
+	if _, err := os.Stat(filepath.Join(outputDir, ManifestFile)); err != nil {
+		t.Fatalf("Expected manifest to be written: %v", err)
+	}
+
+	// Nothing changed
+	past := ageOutputs(t, outputDir)
+	generate()
+	expectRewritten(t, outputDir, past)
+
+	// A changed source only renders its page
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A\n\nChanged")
+	past = ageOutputs(t, outputDir)
+	generate()
+	expectRewritten(t, outputDir, past, "a.html")
+
+	// A new page changes the listing of its directory, and the previous page
+	// of the page after it
+	writeFile(t, filepath.Join(rootDir, "c.md"), "# C")
+	past = ageOutputs(t, outputDir)
+	generate()
+	expectRewritten(t, outputDir, past, "a.html", "b.html", "c.html", "guide/index.html", "index.html")
+
+	// A removed page is deleted from the output
+	if err := os.Remove(filepath.Join(rootDir, "c.md")); err != nil {
+		t.Fatal(err)
+	}
+	past = ageOutputs(t, outputDir)
+	generate()
+	expectRewritten(t, outputDir, past, "a.html", "b.html", "guide/index.html", "index.html")
+	if _, err := os.Stat(filepath.Join(outputDir, "c.html")); !os.IsNotExist(err) {
+		t.Error("Expected output of removed page to be deleted")
+	}
+
+	// Changing options that affect the templates renders everything
+	past = ageOutputs(t, outputDir)
+	generate(WithLiveReload(true))
+	expectRewritten(t, outputDir, past, "a.html", "b.html", "guide/index.html", "guide/setup.html", "index.html")
+
+	// Without incremental builds everything is rendered
+	past = ageOutputs(t, outputDir)
+	generate(WithLiveReload(true), WithIncremental(false))
+	expectRewritten(t, outputDir, past, "a.html", "b.html", "guide/index.html", "guide/setup.html", "index.html")
+
+	// A deleted output is generated again
+	if err := os.Remove(filepath.Join(outputDir, "b.html")); err != nil {
+		t.Fatal(err)
+	}
+	past = ageOutputs(t, outputDir)
+	generate(WithLiveReload(true))
+	if _, err := os.Stat(filepath.Join(outputDir, "b.html")); err != nil {
+		t.Errorf("Expected deleted output to be generated again: %v", err)
+	}
+}
+
+func TestLoadManifest(t *testing.T) {
+	outputDir := t.TempDir()
+
+	// A missing manifest is empty
+	m := loadManifest(outputDir, "templates")
+	if len(m.Outputs) != 0 {
+		t.Errorf("Expected empty manifest, but got %v", m.Outputs)
+	}
+
+	m.set("a.html", ManifestEntry{Source: "a.md", SourceHash: "1"})
+	if err := m.save(outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	m = loadManifest(outputDir, "templates")
+	if entry, ok := m.get("a.html"); !ok || entry.SourceHash != "1" {
+		t.Errorf("Expected manifest to contain 'a.html', but got %v", m.Outputs)
+	}
+
+	// Changed templates invalidate the manifest
+	m = loadManifest(outputDir, "other")
+	if len(m.Outputs) != 0 {
+		t.Errorf("Expected empty manifest after template change, but got %v", m.Outputs)
+	}
+
+	// A corrupt manifest is empty
+	writeFile(t, filepath.Join(outputDir, ManifestFile), "{")
+	m = loadManifest(outputDir, "templates")
+	if len(m.Outputs) != 0 {
+		t.Errorf("Expected empty manifest, but got %v", m.Outputs)
+	}
+}
+
+// ageOutputs sets the modification time of all HTML outputs to the past
+func ageOutputs(t *testing.T, outputDir string) time.Time {
+	t.Helper()
+
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+		return os.Chtimes(path, past, past)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return past
+}
+
+// expectRewritten checks that exactly the expected outputs were written since
+// they were aged
+func expectRewritten(t *testing.T, outputDir string, past time.Time, expected ...string) {
+	t.Helper()
+
+	var rewritten []string
+	err := filepath.WalkDir(outputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".html" {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if !info.ModTime().Equal(past) {
+			relPath, err := filepath.Rel(outputDir, path)
+			if err != nil {
+				return err
+			}
+			rewritten = append(rewritten, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(rewritten)
+	if !slices.Equal(rewritten, expected) {
+		t.Errorf("Expected %v to be rewritten, but got %v", expected, rewritten)
+	}
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MetaFile is the name of the file in a directory that sets how its entries
+// are shown in the navigation. Like all files starting with an underscore it
+// is not part of the output.
+const MetaFile = "_meta.yaml"
+
+// DirMeta is the navigation metadata of a directory
+type DirMeta struct {
+	// Title is the display title of the directory itself
+	Title string `yaml:"title"`
+	// Order lists entries by name, with or without extension, to show them
+	// first and in this order
+	Order []string `yaml:"order"`
+	// Titles maps entry names to their display titles
+	Titles map[string]string `yaml:"titles"`
+	// Hidden lists entries that are left out of the navigation, hidden
+	// pages are still generated
+	Hidden []string `yaml:"hidden"`
+	// Links are external links added to the navigation
+	Links []MetaLink `yaml:"links"`
+}
+
+// MetaLink is an external link in the navigation
+type MetaLink struct {
+	Title string `yaml:"title"`
+	URL   string `yaml:"url"`
+}
+
+// readDirMeta reads the metadata file of a directory, a missing file results
+// in empty metadata
+func readDirMeta(dir string) (DirMeta, error) {
+	var meta DirMeta
+
+	data, err := os.ReadFile(filepath.Join(dir, MetaFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return meta, nil
+	}
+	if err != nil {
+		return meta, err
+	}
+
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("%s: %w", filepath.Join(dir, MetaFile), err)
+	}
+
+	for _, link := range meta.Links {
+		if link.Title == "" || link.URL == "" {
+			return meta, fmt.Errorf("%s: links need a title and a url", filepath.Join(dir, MetaFile))
+		}
+	}
+
+	return meta, nil
+}
+
+// entryKey is the name entries are referred to by in the metadata, without
+// the markdown extension
+func (p *Parser) entryKey(name string) string {
+	if p.isMarkdownFile(name) {
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// title returns the display title of an entry, which can be referred to with
+// or without its extension
+func (m DirMeta) title(name, key string) (string, bool) {
+	if title, ok := m.Titles[name]; ok {
+		return title, true
+	}
+	title, ok := m.Titles[key]
+	return title, ok
+}
+
+func (m DirMeta) isHidden(name, key string) bool {
+	return slices.Contains(m.Hidden, name) || slices.Contains(m.Hidden, key)
+}
+
+// rank returns the position of an entry in the explicit order, or -1
+func (m DirMeta) rank(name, key string) int {
+	for i, ordered := range m.Order {
+		if ordered == name || ordered == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// dirTitle returns the title of a directory from its metadata or the front
+// matter of its index page, if any
+func (p *Parser) dirTitle(dir string) (string, int) {
+	var title string
+	var weight int
+
+	if index, ok := p.indexPageFor(dir); ok {
+		if page, err := p.readPageMetadata(index); err == nil && p.isPublished(page) {
+			title = page.Title
+			weight = page.Weight
+		}
+	}
+
+	// Errors are reported by the listing of the directory itself
+	if meta, err := readDirMeta(dir); err == nil && meta.Title != "" {
+		title = meta.Title
+	}
+
+	return title, weight
+}
+
+// sortListing orders the entries of a directory: first the ones in the
+// explicit order of the metadata, then by weight and title, and external
+// links last
+func (p *Parser) sortListing(files []FileEntry, meta DirMeta) {
+	slices.SortStableFunc(files, func(a, b FileEntry) int {
+		rankA, rankB := meta.rank(a.Name, p.entryKey(a.Name)), meta.rank(b.Name, p.entryKey(b.Name))
+		switch {
+		case rankA >= 0 && rankB >= 0:
+			return rankA - rankB
+		case rankA >= 0:
+			return -1
+		case rankB >= 0:
+			return 1
+		}
+
+		// External links come after the pages
+		if a.External != b.External {
+			if a.External {
+				return 1
+			}
+			return -1
+		}
+
+		if c := compareWeight(a.Weight, b.Weight); c != 0 {
+			return c
+		}
+
+		if c := strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+}
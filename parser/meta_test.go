@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetDirectoryListingMeta(t *testing.T) {
+	rootDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "guide", "index.md"), "---\ntitle: User Guide\nweight: 5\n---\n# Guide")
+	writeFile(t, filepath.Join(rootDir, "changelog.md"), "# Changelog")
+	writeFile(t, filepath.Join(rootDir, "intro.md"), "# Intro")
+	writeFile(t, filepath.Join(rootDir, "faq.md"), "---\ntitle: Questions\n---\n# FAQ")
+	writeFile(t, filepath.Join(rootDir, "about.md"), "---\nweight: 1\n---\n# About")
+	writeFile(t, filepath.Join(rootDir, "secret.md"), "# Secret")
+	writeFile(t, filepath.Join(rootDir, MetaFile), `order: [intro, changelog.md]
+titles:
+  intro: Getting Started
+  faq.md: Frequently Asked
+hidden: [secret]
+links:
+  - title: Source
+    url: https://github.com/jpbruinsslot/mdex
+`)
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir))
+
+	entries, err := p.getDirectoryListing(rootDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Explicit order first, then by weight and title, and links last
+	expected := []struct {
+		title string
+		url   string
+	}{
+		{"Getting Started", "/intro"},
+		{"Changelog", "/changelog"},
+		{"About", "/about"},
+		{"User Guide", "/guide/"},
+		{"Frequently Asked", "/faq"},
+		{"Source", "https://github.com/jpbruinsslot/mdex"},
+	}
+
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %d entries, but got %d: %+v", len(expected), len(entries), entries)
+	}
+
+	for i, entry := range entries {
+		if entry.Title != expected[i].title || entry.URL != expected[i].url {
+			t.Errorf("Expected entry '%s' (%s), but got '%s' (%s)", expected[i].title, expected[i].url, entry.Title, entry.URL)
+		}
+	}
+
+	if !entries[len(entries)-1].External {
+		t.Error("Expected the link to be external")
+	}
+}
+
+func TestGetDirectoryListingMetaInvalid(t *testing.T) {
+	rootDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+	writeFile(t, filepath.Join(rootDir, MetaFile), "links:\n  - title: Missing URL\n")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir))
+
+	_, err := p.getDirectoryListing(rootDir)
+	if err == nil {
+		t.Fatal("Expected an error, but got nil")
+	}
+	if !strings.Contains(err.Error(), MetaFile) {
+		t.Errorf("Expected error to mention '%s', but got '%v'", MetaFile, err)
+	}
+}
+
+func TestGenerateMeta(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+	writeFile(t, filepath.Join(rootDir, "hidden.md"), "# Hidden")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+	writeFile(t, filepath.Join(rootDir, "guide", MetaFile), "title: User Guide\n")
+	writeFile(t, filepath.Join(rootDir, MetaFile), `hidden: [hidden]
+links:
+  - title: Source
+    url: https://github.com/jpbruinsslot/mdex
+`)
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hidden pages are still generated, but not shown
+	if _, err := os.Stat(filepath.Join(outputDir, "hidden.html")); err != nil {
+		t.Errorf("Expected 'hidden.html' to be generated: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outputDir, MetaFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected '%s' not to be copied to the output", MetaFile)
+	}
+
+	page := filepath.Join(outputDir, "a.html")
+	expectNotContains(t, page, `href="/hidden"`)
+	expectContains(t, page, `<a href="/guide/">User Guide</a>`)
+	expectContains(t, page, `<a href="https://github.com/jpbruinsslot/mdex">Source</a>`)
+
+	// Changing the metadata updates the navigation of every page
+	writeFile(t, filepath.Join(rootDir, "guide", MetaFile), "title: Handbook\n")
+	changes, err := p.Regenerate([]string{filepath.Join(rootDir, "guide", MetaFile)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.All {
+		t.Error("Expected all pages to be affected by a change of the metadata")
+	}
+
+	expectContains(t, page, `<a href="/guide/">Handbook</a>`)
+	expectContains(t, filepath.Join(outputDir, "guide", "setup.html"), `<a href="/guide/">Handbook</a>`)
+}
@@ -0,0 +1,229 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strings"
+)
+
+// NavItem is a directory or page in the navigation tree of the site
+type NavItem struct {
+	Title string
+	// URL is the link to the item, including the base path
+	URL   string
+	IsDir bool
+	// Current is set for the page that is being rendered, and Open for the
+	// directories that contain it
+	Current  bool
+	Open     bool
+	Children []NavItem
+	// External is set for links to other sites
+	External bool
+
+	// path is the source file or directory of the item
+	path string
+}
+
+// PageLink is a link to another page of the site
+type PageLink struct {
+	Title string
+	// URL is the link to the page, including the base path
+	URL string
+}
+
+// PageLinks are the links of a page to the pages around it
+type PageLinks struct {
+	// Breadcrumbs lead from the home page to the page itself
+	Breadcrumbs []PageLink
+	// Prev and Next are the pages before and after the page, in the order
+	// of the navigation
+	Prev *PageLink
+	Next *PageLink
+}
+
+// updateNav builds the navigation tree from the directory listings, and
+// reports whether it changed
+func (p *Parser) updateNav() bool {
+	// The root directory is the home page of the site
+	home := NavItem{Title: "Home", URL: p.BasePath + "/", path: p.RootPath}
+	if title, _ := p.dirTitle(p.RootPath); title != "" {
+		home.Title = title
+	}
+
+	nav := append([]NavItem{home}, p.buildNav(p.RootPath)...)
+	hash := hashNav(nav)
+
+	changed := hash != p.navHash
+	p.nav = nav
+	p.navHash = hash
+	p.pages = flattenNav(nav, nil)
+
+	return changed
+}
+
+// flattenNav appends the pages of the navigation tree in the order they are
+// shown, with every directory before its children
+func flattenNav(items []NavItem, pages []NavItem) []NavItem {
+	for _, item := range items {
+		if item.External {
+			continue
+		}
+
+		pages = append(pages, item)
+		pages = flattenNav(item.Children, pages)
+	}
+	return pages
+}
+
+// buildNav returns the navigation items of a directory, in the order and
+// with the titles of its listing
+func (p *Parser) buildNav(dir string) []NavItem {
+	files, _ := p.listing(dir)
+
+	var items []NavItem
+	for _, entry := range files {
+		// The index page is the directory itself
+		if entry.Name == ".." || (!entry.IsDir && !entry.External && p.isIndexPage(entry.Name)) {
+			continue
+		}
+
+		item := NavItem{
+			Title:    entry.Title,
+			URL:      p.BasePath + entry.URL,
+			IsDir:    entry.IsDir,
+			External: entry.External,
+			path:     entry.Path,
+		}
+
+		if entry.External {
+			item.URL = entry.URL
+		}
+
+		if entry.IsDir {
+			item.Children = p.buildNav(entry.Path)
+		}
+
+		items = append(items, item)
+	}
+
+	return items
+}
+
+// navFor returns the navigation tree with the page at route marked as
+// current. Only the branch that leads to the page is copied, the rest is
+// shared with the tree of the build.
+func (p *Parser) navFor(route string) []NavItem {
+	if p.FlatNav {
+		return nil
+	}
+	return markNav(p.nav, p.BasePath+route)
+}
+
+// pageLinks returns the breadcrumbs and the previous and next pages of the
+// page at route. Pages that are not in the navigation only get breadcrumbs,
+// with the given title for the page itself.
+func (p *Parser) pageLinks(route, title string) PageLinks {
+	var links PageLinks
+	if len(p.nav) == 0 {
+		return links
+	}
+
+	url := p.BasePath + route
+
+	home := p.nav[0]
+	links.Breadcrumbs = append(links.Breadcrumbs, PageLink{Title: home.Title, URL: home.URL})
+
+	items := p.nav[1:]
+	for len(items) > 0 {
+		var next []NavItem
+		for _, item := range items {
+			if item.External || (item.URL != url && !(item.IsDir && strings.HasPrefix(url, item.URL))) {
+				continue
+			}
+
+			links.Breadcrumbs = append(links.Breadcrumbs, PageLink{Title: item.Title, URL: item.URL})
+			next = item.Children
+			break
+		}
+		items = next
+	}
+
+	if last := links.Breadcrumbs[len(links.Breadcrumbs)-1]; last.URL != url {
+		links.Breadcrumbs = append(links.Breadcrumbs, PageLink{Title: title, URL: url})
+	}
+
+	for i, page := range p.pages {
+		if page.URL != url {
+			continue
+		}
+
+		if i > 0 {
+			links.Prev = &PageLink{Title: p.pages[i-1].Title, URL: p.pages[i-1].URL}
+		}
+		if i < len(p.pages)-1 {
+			links.Next = &PageLink{Title: p.pages[i+1].Title, URL: p.pages[i+1].URL}
+		}
+		break
+	}
+
+	return links
+}
+
+// pageLinkHashes hashes the links of every page in the navigation, keyed by
+// its source path
+func (p *Parser) pageLinkHashes() map[string]string {
+	hashes := make(map[string]string, len(p.pages))
+	for _, page := range p.pages {
+		hashes[page.path] = hashPageLinks(p.pageLinks(strings.TrimPrefix(page.URL, p.BasePath), page.Title))
+	}
+	return hashes
+}
+
+func markNav(items []NavItem, url string) []NavItem {
+	for i, item := range items {
+		if item.External || (item.URL != url && !(item.IsDir && strings.HasPrefix(url, item.URL))) {
+			continue
+		}
+
+		marked := slices.Clone(items)
+		marked[i].Current = item.URL == url
+		if item.IsDir {
+			marked[i].Open = true
+			marked[i].Children = markNav(item.Children, url)
+		}
+		return marked
+	}
+
+	return items
+}
+
+// compareWeight orders items with a weight before items without one
+func compareWeight(a, b int) int {
+	switch {
+	case a == b:
+		return 0
+	case a == 0:
+		return 1
+	case b == 0:
+		return -1
+	default:
+		return cmp.Compare(a, b)
+	}
+}
+
+func hashPageLinks(links PageLinks) string {
+	data, err := json.Marshal(links)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(data)
+}
+
+func hashNav(items []NavItem) string {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(data)
+}
@@ -0,0 +1,243 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGenerateNav(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	for _, dir := range []string{"guide/advanced", "reference"} {
+		if err := os.MkdirAll(filepath.Join(rootDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(rootDir, "index.md"), "---\ntitle: Docs\n---\n# Docs")
+	writeFile(t, filepath.Join(rootDir, "about.md"), "# About")
+	writeFile(t, filepath.Join(rootDir, "intro.md"), "---\ntitle: Introduction\nweight: 1\n---\n# Intro")
+	writeFile(t, filepath.Join(rootDir, "guide", "index.md"), "---\ntitle: User Guide\n---\n# Guide")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+	writeFile(t, filepath.Join(rootDir, "guide", "advanced", "tuning.md"), "# Tuning")
+	writeFile(t, filepath.Join(rootDir, "reference", "api.md"), "# API")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	type item struct {
+		title    string
+		url      string
+		children []item
+	}
+
+	expected := []item{
+		{title: "Docs", url: "/"},
+		{title: "Introduction", url: "/intro"},
+		{title: "About", url: "/about"},
+		{title: "reference", url: "/reference/", children: []item{
+			{title: "API", url: "/reference/api"},
+		}},
+		{title: "User Guide", url: "/guide/", children: []item{
+			{title: "advanced", url: "/guide/advanced/", children: []item{
+				{title: "Tuning", url: "/guide/advanced/tuning"},
+			}},
+			{title: "Setup", url: "/guide/setup"},
+		}},
+	}
+
+	var compare func(path string, expected []item, actual []NavItem)
+	compare = func(path string, expected []item, actual []NavItem) {
+		if len(actual) != len(expected) {
+			t.Fatalf("Expected %d items in '%s', but got %d: %+v", len(expected), path, len(actual), actual)
+		}
+		for i := range expected {
+			if actual[i].Title != expected[i].title || actual[i].URL != expected[i].url {
+				t.Errorf("Expected item '%s' (%s), but got '%s' (%s)", expected[i].title, expected[i].url, actual[i].Title, actual[i].URL)
+			}
+			compare(expected[i].url, expected[i].children, actual[i].Children)
+		}
+	}
+	compare("/", expected, p.nav)
+
+	// The current page is highlighted and its ancestors are expanded
+	tuning := filepath.Join(outputDir, "guide", "advanced", "tuning.html")
+	expectContains(t, tuning, `<a href="/guide/advanced/tuning" class="current" aria-current="page">Tuning</a>`)
+	expectContains(t, tuning, `<details open>
+      <summary>
+        <svg class="icon">
+          <use href="/static/img/feather-sprite.svg#folder" />
+        </svg>
+        <a href="/guide/">User Guide</a>`)
+	expectContains(t, tuning, `<details>
+      <summary>
+        <svg class="icon">
+          <use href="/static/img/feather-sprite.svg#folder" />
+        </svg>
+        <a href="/reference/">reference</a>`)
+
+	// Directory pages are highlighted in the tree as well
+	expectContains(t, filepath.Join(outputDir, "guide", "index.html"), `<a href="/guide/" class="current" aria-current="page">User Guide</a>`)
+}
+
+func TestMarkNav(t *testing.T) {
+	nav := []NavItem{
+		{Title: "Home", URL: "/"},
+		{Title: "guide", URL: "/guide/", IsDir: true, Children: []NavItem{
+			{Title: "setup", URL: "/guide/setup"},
+		}},
+		{Title: "guide page", URL: "/guide"},
+	}
+
+	marked := markNav(nav, "/guide/setup")
+
+	if marked[0].Current || marked[2].Current {
+		t.Error("Expected only the current page to be marked")
+	}
+	if !marked[1].Open || marked[1].Current {
+		t.Error("Expected the parent directory to be open but not current")
+	}
+	if !marked[1].Children[0].Current {
+		t.Error("Expected the page to be current")
+	}
+
+	// The tree of the build is not modified
+	if nav[1].Open || nav[1].Children[0].Current {
+		t.Error("Expected the shared tree not to be modified")
+	}
+}
+
+func TestRegenerateNav(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new page in one directory is added to the tree of every page
+	install := filepath.Join(rootDir, "guide", "install.md")
+	writeFile(t, install, "# Install")
+
+	changes, err := p.Regenerate([]string{install})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.All {
+		t.Error("Expected all pages to be affected by a change of the navigation tree")
+	}
+
+	expectContains(t, filepath.Join(outputDir, "a.html"), `href="/guide/install"`)
+	expectContains(t, filepath.Join(outputDir, "index.html"), `href="/guide/install"`)
+
+	// Changing only the content keeps the tree as it is
+	writeFile(t, install, "# Install\n\nUpdated")
+	changes, err = p.Regenerate([]string{install})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.All || changes.Structural {
+		t.Errorf("Expected only the page to be affected, but got %+v", changes)
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "index.md"), "# Docs")
+	writeFile(t, filepath.Join(rootDir, "intro.md"), "---\nweight: 1\n---\n# Introduction")
+	writeFile(t, filepath.Join(rootDir, "guide", "index.md"), "# User Guide")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+	writeFile(t, filepath.Join(rootDir, "hidden.md"), "# Hidden")
+	writeFile(t, filepath.Join(rootDir, MetaFile), "hidden: [hidden]\n")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithBaseURL("/docs"))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	links := p.pageLinks("/guide/setup", "Setup")
+
+	expected := []PageLink{
+		{Title: "Docs", URL: "/docs/"},
+		{Title: "User Guide", URL: "/docs/guide/"},
+		{Title: "Setup", URL: "/docs/guide/setup"},
+	}
+	if !slices.Equal(links.Breadcrumbs, expected) {
+		t.Errorf("Expected breadcrumbs %v, but got %v", expected, links.Breadcrumbs)
+	}
+	if links.Prev == nil || *links.Prev != (PageLink{Title: "User Guide", URL: "/docs/guide/"}) {
+		t.Errorf("Expected previous page 'User Guide', but got %v", links.Prev)
+	}
+	if links.Next != nil {
+		t.Errorf("Expected no next page, but got %v", links.Next)
+	}
+
+	// The order follows the sidebar, with directories before their pages
+	links = p.pageLinks("/", "Docs")
+	if links.Prev != nil || links.Next == nil || links.Next.URL != "/docs/intro" {
+		t.Errorf("Expected only next page '/docs/intro', but got %v and %v", links.Prev, links.Next)
+	}
+
+	// Pages that are not in the navigation only get breadcrumbs
+	links = p.pageLinks("/hidden", "Hidden")
+	if len(links.Breadcrumbs) != 2 || links.Prev != nil || links.Next != nil {
+		t.Errorf("Expected only breadcrumbs for a hidden page, but got %+v", links)
+	}
+
+	page := filepath.Join(outputDir, "guide", "setup.html")
+	expectContains(t, page, `<li><a href="/docs/guide/">User Guide</a></li>`)
+	expectContains(t, page, `<li><span aria-current="page">Setup</span></li>`)
+	expectContains(t, page, `<a class="prev" href="/docs/guide/" rel="prev">`)
+	expectNotContains(t, page, `rel="next"`)
+}
+
+func TestRegeneratePageLinks(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+	writeFile(t, filepath.Join(rootDir, "z.md"), "# Z")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithFlatNav(true))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// With a flat sidebar, pages in other directories are only rendered
+	// again when the pages around them changed
+	tuning := filepath.Join(rootDir, "guide", "tuning.md")
+	writeFile(t, tuning, "# Tuning")
+
+	changes, err := p.Regenerate([]string{tuning})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(changes.Routes, "/z") {
+		t.Errorf("Expected '/z' to be rendered again, but got %+v", changes)
+	}
+	if slices.Contains(changes.Routes, "/a") {
+		t.Errorf("Expected '/a' not to be rendered again, but got %+v", changes)
+	}
+
+	expectContains(t, filepath.Join(outputDir, "z.html"), `<a class="prev" href="/guide/tuning" rel="prev">`)
+}
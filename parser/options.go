@@ -1,8 +1,32 @@
 package parser
 
+import "github.com/jpbruinsslot/mdex/acl"
+
 type Options struct {
-	RootPath   string
-	OutputPath string
+	RootPath    string
+	OutputPath  string
+	BuildDrafts bool
+	BuildFuture bool
+	LiveReload  bool
+	Incremental bool
+	Jobs        int
+	FailFast    bool
+	BaseURL     string
+	FlatNav     bool
+	Search      bool
+	// ServerSearch has the search box use the search endpoint of mdex
+	// serve, see WithServerSearch
+	ServerSearch bool
+	// ExternalAllowlist, when not nil, is used by Check to report external
+	// links that are not allowed
+	ExternalAllowlist []string
+	// Access is what the site is generated for, see WithAccess
+	Access acl.Access
+	// ExcludedFiles are never copied to the output, see WithExcludedFiles
+	ExcludedFiles []string
+	// Precompress writes gzip compressed siblings of the outputs, see
+	// WithPrecompress
+	Precompress bool
 }
 
 type Option func(o *Options)
@@ -18,3 +42,113 @@ func WithOutputPath(path string) Option {
 		o.OutputPath = path
 	}
 }
+
+// WithDrafts includes pages marked as draft in the output
+func WithDrafts(drafts bool) Option {
+	return func(o *Options) {
+		o.BuildDrafts = drafts
+	}
+}
+
+// WithFuture includes pages with a publish date in the future in the output
+func WithFuture(future bool) Option {
+	return func(o *Options) {
+		o.BuildFuture = future
+	}
+}
+
+// WithLiveReload adds the live reload client script to the generated pages
+func WithLiveReload(liveReload bool) Option {
+	return func(o *Options) {
+		o.LiveReload = liveReload
+	}
+}
+
+// WithIncremental skips outputs whose inputs didn't change since the last
+// build, according to the build manifest in the output directory
+func WithIncremental(incremental bool) Option {
+	return func(o *Options) {
+		o.Incremental = incremental
+	}
+}
+
+// WithJobs sets the number of pages that are rendered in parallel
+func WithJobs(jobs int) Option {
+	return func(o *Options) {
+		o.Jobs = jobs
+	}
+}
+
+// WithFailFast stops generating at the first error, instead of collecting the
+// errors of all files
+func WithFailFast(failFast bool) Option {
+	return func(o *Options) {
+		o.FailFast = failFast
+	}
+}
+
+// WithBaseURL sets the URL the site is hosted at, like
+// https://intranet/eng/docs/, whose path prefixes all generated links
+func WithBaseURL(baseURL string) Option {
+	return func(o *Options) {
+		o.BaseURL = baseURL
+	}
+}
+
+// WithFlatNav renders only the current directory in the sidebar, instead of
+// the navigation tree of the whole site, which is lighter for very large sites
+func WithFlatNav(flat bool) Option {
+	return func(o *Options) {
+		o.FlatNav = flat
+	}
+}
+
+// WithSearch generates a search index and adds a search box to the pages
+func WithSearch(search bool) Option {
+	return func(o *Options) {
+		o.Search = search
+	}
+}
+
+// WithServerSearch has the search box use the search endpoint of mdex serve,
+// for sites that are too large for the search index of the browser
+func WithServerSearch(serverSearch bool) Option {
+	return func(o *Options) {
+		o.ServerSearch = serverSearch
+	}
+}
+
+// WithExternalAllowlist has Check report external links that don't match one
+// of the entries, see ReadAllowlist
+func WithExternalAllowlist(allowlist []string) Option {
+	return func(o *Options) {
+		o.ExternalAllowlist = allowlist
+	}
+}
+
+// WithAccess generates the site as it is seen with the access. Pages and
+// directories that can't be accessed are left out, along with their entries
+// in the navigation and the search index.
+func WithAccess(access acl.Access) Option {
+	return func(o *Options) {
+		o.Access = access
+	}
+}
+
+// WithExcludedFiles never copies the files to the output, even when their
+// extension is one of the assets, like the htpasswd and access rules files
+// when they are kept next to the markdown files
+func WithExcludedFiles(paths ...string) Option {
+	return func(o *Options) {
+		o.ExcludedFiles = append(o.ExcludedFiles, paths...)
+	}
+}
+
+// WithPrecompress writes a gzip compressed .gz sibling of every page, script,
+// stylesheet and search index file, which mdex serve sends to the browsers
+// that accept gzip instead of compressing them on every request
+func WithPrecompress(precompress bool) Option {
+	return func(o *Options) {
+		o.Precompress = precompress
+	}
+}
@@ -1,16 +1,21 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"html/template"
-	"io/fs"
 	"log"
 	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath"
-	"slices"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/search"
 	"github.com/jpbruinsslot/mdex/templates"
 )
 
@@ -20,19 +25,113 @@ type MarkdownParser interface {
 }
 
 type Parser struct {
-	Logger     *slog.Logger
-	Templates  map[string]*template.Template
-	RootPath   string
-	OutputPath string
-	Parser     MarkdownParser
+	Logger      *slog.Logger
+	Templates   map[string]*template.Template
+	RootPath    string
+	OutputPath  string
+	BuildDrafts bool
+	BuildFuture bool
+	LiveReload  bool
+	Incremental bool
+	Jobs        int
+	FailFast    bool
+	FlatNav     bool
+	Search      bool
+	// ServerSearch has the search box use the search endpoint of the
+	// server, instead of the search index in the browser
+	ServerSearch bool
+	Parser       MarkdownParser
+
+	// BasePath is the path of the base URL without trailing slash, which
+	// prefixes all generated links, or empty when hosted at the root
+	BasePath string
+
+	// ExternalAllowlist, when not nil, is used by Check to report external
+	// links that are not allowed
+	ExternalAllowlist []string
+
+	// Access is what the site is generated for, pages that can't be
+	// accessed are left out
+	Access acl.Access
+
+	// ExcludedFiles are never copied to the output as assets
+	ExcludedFiles []string
+
+	// Precompress writes a gzip compressed .gz sibling of the outputs that
+	// compress well
+	Precompress bool
+
+	// OnRegenerate is called with the changes after every regeneration
+	// triggered by Watch
+	OnRegenerate func(Changes)
+
+	// listings holds the directory listings of the last build, keyed by
+	// directory
+	listings   map[string][]FileEntry
+	listingsMu sync.RWMutex
+
+	// nav is the navigation tree of the site, built from the listings, and
+	// pages are its pages in order
+	nav     []NavItem
+	navHash string
+	pages   []NavItem
+
+	// searchDocs are the documents of the search index, keyed by source
+	// path
+	searchDocs map[string]searchDoc
+	searchMu   sync.Mutex
+
+	// previous is the manifest of the last build, manifest the one of the
+	// current build
+	previous *Manifest
+	manifest *Manifest
+
+	// now returns the current time, used to filter on publish and expiry
+	// dates
+	now func() time.Time
+
+	// options the parser was created with, to create parsers for other
+	// accesses
+	options Options
+	// outputRoot is the directory that holds the output, which is the output
+	// path itself unless it is one of several builds
+	outputRoot string
 }
 
 type TemplateData struct {
-	Content template.HTML
-	Title   string
-	Files   []FileEntry
-	TOC     []TOCEntry
-	IsIndex bool
+	Content     template.HTML
+	Title       string
+	Description string
+	Date        time.Time
+	Draft       bool
+	Weight      int
+	Tags        []string
+	Page        Page
+	Files       []FileEntry
+	TOC         []TOCEntry
+	IsIndex     bool
+	LiveReload  bool
+	// Search shows the search box, which uses the search index of the
+	// browser, or the search endpoint of the server with ServerSearch
+	Search       bool
+	ServerSearch bool
+	// Nav is the navigation tree of the site, unless the flat navigation
+	// of Files is used
+	Nav []NavItem
+	// BasePath prefixes all site-relative links
+	BasePath string
+	// URL is the site-relative URL of the page
+	URL string
+	PageLinks
+	// SearchResults are set on the search results page of the server
+	SearchResults *SearchResults
+}
+
+// SearchResults are the results of a search on the server
+type SearchResults struct {
+	Query   string
+	Total   int
+	Results []search.Result
 }
 
 type FileEntry struct {
@@ -40,6 +139,15 @@ type FileEntry struct {
 	IsDir     bool
 	Path      string
 	RoutePath string
+	// URL is the site-relative URL of the entry, like /guide/ for a
+	// directory and /guide/setup for a page
+	URL string
+	// Title is the display title, from the metadata of the directory, the
+	// front matter, or the name without extension
+	Title  string
+	Weight int
+	// External is set for links to other sites
+	External bool
 }
 
 type TOCEntry struct {
@@ -50,14 +158,21 @@ type TOCEntry struct {
 
 func New(mdParser MarkdownParser, opts ...Option) *Parser {
 	options := &Options{
-		RootPath:   "",
-		OutputPath: "./public",
+		RootPath:    "",
+		OutputPath:  "./public",
+		Incremental: true,
+		Jobs:        runtime.GOMAXPROCS(0),
+		Search:      true,
 	}
 
 	for _, opt := range opts {
 		opt(options)
 	}
 
+	if options.Jobs < 1 {
+		options.Jobs = 1
+	}
+
 	if options.RootPath == "" {
 		currentWd, err := os.Getwd()
 		if err != nil {
@@ -67,23 +182,65 @@ func New(mdParser MarkdownParser, opts ...Option) *Parser {
 	}
 
 	p := &Parser{
-		Logger:     slog.Default(),
-		Templates:  make(map[string]*template.Template),
-		RootPath:   options.RootPath,
-		OutputPath: options.OutputPath,
-		Parser:     mdParser,
+		Logger:      slog.Default(),
+		Templates:   make(map[string]*template.Template),
+		RootPath:    options.RootPath,
+		OutputPath:  options.OutputPath,
+		BuildDrafts: options.BuildDrafts,
+		BuildFuture: options.BuildFuture,
+		LiveReload:  options.LiveReload,
+		Incremental: options.Incremental,
+		Jobs:        options.Jobs,
+		FailFast:    options.FailFast,
+		FlatNav:     options.FlatNav,
+		Parser:      mdParser,
+		BasePath:    BasePath(options.BaseURL),
+
+		// The server searches its own index, so the one of the browser
+		// isn't needed
+		Search:       options.Search && !options.ServerSearch,
+		ServerSearch: options.ServerSearch,
+
+		ExternalAllowlist: options.ExternalAllowlist,
+		Access:            options.Access,
+		ExcludedFiles:     options.ExcludedFiles,
+		Precompress:       options.Precompress,
+
+		listings:   make(map[string][]FileEntry),
+		searchDocs: make(map[string]searchDoc),
+		manifest:   newManifest(""),
+		now:        time.Now,
+		options:    *options,
+		outputRoot: options.OutputPath,
 	}
+	p.previous = p.manifest
 	p.loadEmbeddedTemplates()
 
+	// Links in the markdown need the base path as well
+	if withBasePath, ok := mdParser.(BasePathParser); ok && p.BasePath != "" {
+		p.Parser = withBasePath.WithBasePath(p.BasePath)
+	}
+
 	return p
 }
 
 func (p *Parser) loadEmbeddedTemplates() {
-	templateFiles, err := templates.FS.ReadDir(".")
+	tmpls, err := ParseTemplates()
 	if err != nil {
 		log.Fatal(err)
 	}
+	p.Templates = tmpls
+}
+
+// ParseTemplates parses the embedded templates, keyed by their name without
+// extension. Every template is rendered within base.html.
+func ParseTemplates() (map[string]*template.Template, error) {
+	templateFiles, err := templates.FS.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
 
+	tmpls := make(map[string]*template.Template)
 	for _, file := range templateFiles {
 		name := file.Name()
 
@@ -92,14 +249,26 @@ func (p *Parser) loadEmbeddedTemplates() {
 		}
 
 		// Parse base.html + the current file together
-		tmpl, err := template.ParseFS(templates.FS, "base.html", "sidebar.html", "toc.html", name)
+		tmpl, err := template.New("base.html").Funcs(templateFuncs).ParseFS(templates.FS, "base.html", "sidebar.html", "toc.html", name)
 		if err != nil {
-			log.Fatalf("failed to parse template %s: %v", name, err)
+			return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
 		}
 
 		key := strings.TrimSuffix(name, ".html")
-		p.Templates[key] = tmpl
+		tmpls[key] = tmpl
 	}
+
+	return tmpls, nil
+}
+
+// templateFuncs are the functions available in the templates
+var templateFuncs = template.FuncMap{
+	// withNav replaces the navigation items, to render a level of the
+	// navigation tree with the rest of the page data
+	"withNav": func(data TemplateData, nav []NavItem) TemplateData {
+		data.Nav = nav
+		return data
+	},
 }
 
 func (p *Parser) renderTemplate(name string, data TemplateData) (string, error) {
@@ -116,27 +285,102 @@ func (p *Parser) renderTemplate(name string, data TemplateData) (string, error)
 	return buf.String(), nil
 }
 
+// extractMetadata returns the metadata of the page and the markdown without
+// its front matter. Pages without a title in their front matter get the
+// title of their first level 1 heading.
+func (p *Parser) extractMetadata(markdown []byte) (Page, []byte, error) {
+	var page Page
+	var body []byte
+	var err error
+
+	if extractor, ok := p.Parser.(MetadataExtractor); ok {
+		page, body, err = extractor.ExtractMetadata(markdown)
+		if page.Params == nil {
+			page.Params = map[string]any{}
+		}
+	} else {
+		page, body, err = ParseFrontMatter(markdown)
+	}
+
+	if err != nil || page.Title != "" {
+		return page, body, err
+	}
+
+	// Errors are reported when the table of contents is rendered
+	if toc, err := p.Parser.ExtractTOC(body); err == nil {
+		page.Title = headingTitle(toc)
+	}
+
+	return page, body, nil
+}
+
+// headingTitle returns the title of the first level 1 heading, if any
+func headingTitle(toc []TOCEntry) string {
+	for _, entry := range toc {
+		if entry.Level == 1 {
+			return entry.Title
+		}
+	}
+	return ""
+}
+
+// isPublished reports whether the page should be part of the output
+func (p *Parser) isPublished(page Page) bool {
+	return page.IsPublished(p.now(), p.BuildDrafts, p.BuildFuture)
+}
+
+// readPageMetadata reads the markdown file at path and returns its metadata
+func (p *Parser) readPageMetadata(path string) (Page, error) {
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		return Page{}, err
+	}
+
+	page, _, err := p.extractMetadata(markdown)
+	if err != nil {
+		return Page{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return page, nil
+}
+
+// newTemplateData creates the template data for a page from its metadata
+func (p *Parser) newTemplateData(page Page, title string) TemplateData {
+	if page.Title != "" {
+		title = page.Title
+	}
+
+	return TemplateData{
+		Title:        title,
+		Description:  page.Description,
+		Date:         page.Date,
+		Draft:        page.Draft,
+		Weight:       page.Weight,
+		Tags:         page.Tags,
+		Page:         page,
+		LiveReload:   p.LiveReload,
+		Search:       p.Search || p.ServerSearch,
+		ServerSearch: p.ServerSearch,
+		BasePath:     p.BasePath,
+	}
+}
+
 func (p *Parser) getDirectoryListing(root string) ([]FileEntry, error) {
 	entries, err := os.ReadDir(root)
 	if err != nil {
 		return nil, err
 	}
 
-	var result []FileEntry
-
-	// Add .. to go up one directory level
-	if root != p.RootPath {
-		result = append(result, FileEntry{
-			Name:      "..",
-			IsDir:     true,
-			Path:      filepath.Join(root, ".."),
-			RoutePath: strings.TrimSuffix(filepath.Join(root, ".."), ".md"),
-		})
+	meta, err := readDirMeta(root)
+	if err != nil {
+		return nil, err
 	}
 
+	var result []FileEntry
+
 	for _, entry := range entries {
 		// Skip the output directory
-		if entry.Name() == filepath.Base(p.OutputPath) {
+		if entry.Name() == filepath.Base(p.outputRoot) {
 			continue
 		}
 
@@ -150,29 +394,82 @@ func (p *Parser) getDirectoryListing(root string) ([]FileEntry, error) {
 			continue
 		}
 
-		if entry.IsDir() || strings.HasSuffix(entry.Name(), ".md") {
-			result = append(result, FileEntry{
-				Name:      entry.Name(),
-				IsDir:     entry.IsDir(),
-				Path:      filepath.Join(root, entry.Name()),
-				RoutePath: strings.TrimSuffix(filepath.Join(root, entry.Name()), ".md"),
-			})
+		if !entry.IsDir() && !p.isMarkdownFile(entry.Name()) {
+			continue
 		}
-	}
 
-	// Sort: folders first, then files, both alphabetically
-	slices.SortFunc(result, func(a, b FileEntry) int {
-		if a.IsDir && !b.IsDir {
-			return -1
+		key := p.entryKey(entry.Name())
+		if meta.isHidden(entry.Name(), key) {
+			continue
 		}
-		if !a.IsDir && b.IsDir {
-			return 1
+
+		path := filepath.Join(root, entry.Name())
+
+		// Skip what can't be accessed
+		if !p.isAllowed(path, entry.IsDir()) {
+			continue
 		}
-		return strings.Compare(a.Name, b.Name)
-	})
 
-	return result, nil
+		file := FileEntry{
+			Name:      entry.Name(),
+			IsDir:     entry.IsDir(),
+			Path:      path,
+			RoutePath: strings.TrimSuffix(path, filepath.Ext(path)),
+			URL:       p.routeFor(path),
+		}
+
+		if entry.IsDir() {
+			file.RoutePath = path
+			file.Title, file.Weight = p.dirTitle(path)
+		} else {
+			page, err := p.readPageMetadata(path)
+			if err != nil {
+				return nil, err
+			}
+
+			// Skip pages that are not published
+			if !p.isPublished(page) {
+				continue
+			}
+
+			file.Title = page.Title
+			file.Weight = page.Weight
+		}
+
+		if title, ok := meta.title(entry.Name(), key); ok {
+			file.Title = title
+		}
+		if file.Title == "" {
+			file.Title = key
+		}
+
+		result = append(result, file)
+	}
 
+	for _, link := range meta.Links {
+		result = append(result, FileEntry{
+			Name:     link.Title,
+			URL:      link.URL,
+			Title:    link.Title,
+			External: true,
+		})
+	}
+
+	p.sortListing(result, meta)
+
+	// Add .. to go up one directory level
+	if filepath.Clean(root) != filepath.Clean(p.RootPath) {
+		result = append([]FileEntry{{
+			Name:      "..",
+			IsDir:     true,
+			Path:      filepath.Join(root, ".."),
+			RoutePath: filepath.Join(root, ".."),
+			URL:       p.routeFor(filepath.Dir(root)),
+			Title:     "..",
+		}}, result...)
+	}
+
+	return result, nil
 }
 
 // Ignore files and directories that start with an underscore or a dot
@@ -182,145 +479,254 @@ func (p *Parser) isIgnored(name string) bool {
 
 // Check if the file has a .md extension
 func (p *Parser) isMarkdownFile(name string) bool {
+	return hasMarkdownExt(name)
+}
+
+// hasMarkdownExt reports whether the name ends in one of the markdown
+// extensions
+func hasMarkdownExt(name string) bool {
 	return strings.HasSuffix(name, ".md") || strings.HasSuffix(name, ".markdown") || strings.HasSuffix(name, ".mkd")
 }
 
+// Check if the file is the index page of its directory
+func (p *Parser) isIndexPage(name string) bool {
+	return p.isMarkdownFile(name) && strings.TrimSuffix(name, filepath.Ext(name)) == "index"
+}
+
+// indexPageFor returns the path of the index page of a directory, if it has
+// one
+func (p *Parser) indexPageFor(dir string) (string, bool) {
+	for _, name := range []string{"index.md", "index.markdown", "index.mkd"} {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+	return "", false
+}
+
 func (p *Parser) ensureIndexForDir(dir string, files []FileEntry) error {
+	// An index.html is copied as the index, unless it conflicts with an
+	// index page
 	indexPath := filepath.Join(dir, "index.html")
 	if _, err := os.Stat(indexPath); err == nil {
-		return nil // already exists
+		if _, ok := p.indexPageFor(dir); !ok {
+			return nil
+		}
 	}
 
-	content := template.HTML("")
-	toc := []TOCEntry{}
+	relDir, err := filepath.Rel(p.RootPath, dir)
+	if err != nil {
+		return stageError(StageRead, err)
+	}
 
-	// If there is an index.md file, we use that to generate the index
-	indexMDPath := filepath.Join(dir, "index.md")
-	if _, err := os.Stat(indexMDPath); err == nil {
-		markdown, err := os.ReadFile(indexMDPath)
-		if err != nil {
-			return fmt.Errorf("failed to read index.md: %w", err)
-		}
+	outputPath := filepath.Join(p.OutputPath, relDir, "index.html")
 
-		html, err := p.Parser.Convert(markdown)
-		if err != nil {
-			return fmt.Errorf("failed to convert index.md to HTML: %w", err)
-		}
+	view, err := p.prepareIndex(dir, files)
+	if err != nil {
+		return err
+	}
 
-		toc, err = p.Parser.ExtractTOC(markdown)
-		if err != nil {
-			return fmt.Errorf("failed to extract TOC from index.md: %w", err)
+	if view.source != "" {
+		if err := p.indexForSearch(dir, view.data, view.body); err != nil {
+			return asBuildError(view.source, "", err)
 		}
-
-		content = template.HTML(html)
+	} else {
+		p.forgetSearch(dir)
 	}
 
-	indexData := TemplateData{
-		Title:   "Index of " + filepath.Base(dir),
-		Content: content,
-		Files:   files,
-		TOC:     toc,
-		IsIndex: true,
+	if p.isUpToDate(outputPath, view.entry) {
+		p.Logger.Debug("Unchanged", "dir", dir)
+		return nil
 	}
 
-	rendered, err := p.renderTemplate("index", indexData)
+	rendered, err := p.renderView(view)
 	if err != nil {
 		return err
 	}
 
-	outputPath := filepath.Join(p.OutputPath, strings.TrimPrefix(dir, p.RootPath), "index.html")
-	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-		return err
+	if err := p.Save(rendered, outputPath); err != nil {
+		return stageError(StageSave, err)
 	}
 
-	return p.Save(rendered, outputPath)
+	p.record(outputPath, view.entry)
+	return nil
 }
 
+// Generate renders the whole site. Unless failing fast, errors of individual
+// files don't stop the build, they are collected and returned as BuildErrors
+// once every other file has been generated.
 func (p *Parser) Generate() error {
-	return filepath.WalkDir(p.RootPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
+	templateHash, err := p.templateHash()
+	if err != nil {
+		return err
+	}
+
+	p.listings = make(map[string][]FileEntry)
+	p.searchDocs = make(map[string]searchDoc)
+	p.previous = loadManifest(p.OutputPath, templateHash)
+	p.manifest = newManifest(templateHash)
+
+	buildErr := p.generateTree(p.RootPath)
+
+	if buildErr == nil {
+		if err := p.removeStaleOutputs(); err != nil {
 			return err
 		}
+	} else {
+		// Outputs that failed or were not generated keep their previous
+		// inputs, so they are retried by the next build and not removed
+		// as stale
+		p.manifest.carryOver(p.previous)
+	}
 
-		if d.IsDir() {
-			// Skip the output directory
-			if path == p.OutputPath {
-				return nil
-			}
+	// From now on changes are applied to the current manifest
+	p.previous = p.manifest
 
-			// Unless it is the root directory, we skip directories that are ignored
-			if path != p.RootPath && p.isIgnored(d.Name()) {
-				return filepath.SkipDir
-			}
+	if err := p.writeSearchIndex(); err != nil {
+		return errors.Join(buildErr, err)
+	}
 
-			files, err := p.getDirectoryListing(path)
-			if err != nil {
-				return err
-			}
+	if err := p.writeStaticAssets(); err != nil {
+		return errors.Join(buildErr, err)
+	}
 
-			if err := p.ensureIndexForDir(path, files); err != nil {
-				return err
-			}
-			return nil
+	if p.Precompress {
+		if err := p.precompress(); err != nil {
+			return errors.Join(buildErr, err)
 		}
+	}
 
-		if !p.isMarkdownFile(d.Name()) {
-			return nil
-		}
+	if err := p.manifest.save(p.OutputPath); err != nil {
+		return errors.Join(buildErr, err)
+	}
 
-		if p.isIgnored(d.Name()) {
-			return nil
-		}
+	return buildErr
+}
 
-		p.Logger.Info("Processing", "file", path)
+// generateDir renders the index of a directory, and remembers its listing
+func (p *Parser) generateDir(dir string) error {
+	files, err := p.getDirectoryListing(dir)
+	if err != nil {
+		return stageError(StageListing, err)
+	}
+	p.setListing(dir, files)
 
-		markdown, err := os.ReadFile(path)
-		if err != nil {
-			return err
-		}
+	return p.ensureIndexForDir(dir, files)
+}
 
-		html, err := p.Parser.Convert(markdown)
-		if err != nil {
-			return err
-		}
+// generatePage renders a single markdown file
+func (p *Parser) generatePage(path string) error {
+	outputPath, err := p.outputPathFor(path)
+	if err != nil {
+		return stageError(StageRead, err)
+	}
 
-		toc, err := p.Parser.ExtractTOC(markdown)
-		if err != nil {
-			return err
-		}
+	markdown, err := os.ReadFile(path)
+	if err != nil {
+		return stageError(StageRead, err)
+	}
 
-		parentDir := filepath.Dir(path)
-		files, err := p.getDirectoryListing(parentDir)
-		if err != nil {
-			return err
-		}
+	view, err := p.preparePage(path, markdown)
+	if err != nil {
+		return err
+	}
 
-		title := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
-		data := TemplateData{
-			Content: template.HTML(html),
-			Title:   title,
-			Files:   files,
-			TOC:     toc,
-			IsIndex: false,
-		}
+	if view == nil {
+		p.Logger.Info("Skipping unpublished", "file", path)
+		p.forgetSearch(path)
 
-		relPath, err := filepath.Rel(p.RootPath, path)
-		if err != nil {
-			return err
+		// Remove a previously generated version of the page
+		p.forget(outputPath)
+		if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+			return stageError(StageSave, err)
 		}
+		return nil
+	}
 
-		outputPath := filepath.Join(p.OutputPath, strings.TrimSuffix(relPath, filepath.Ext(relPath))+".html")
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
-			return err
-		}
+	if err := p.indexForSearch(path, view.data, view.body); err != nil {
+		return err
+	}
 
-		rendered, err := p.renderTemplate("single", data)
-		if err != nil {
-			return err
-		}
+	if p.isUpToDate(outputPath, view.entry) {
+		p.Logger.Debug("Unchanged", "file", path)
+		return nil
+	}
+
+	p.Logger.Info("Processing", "file", path)
+
+	rendered, err := p.renderView(view)
+	if err != nil {
+		return err
+	}
 
-		return p.Save(rendered, outputPath)
-	})
+	if err := p.Save(rendered, outputPath); err != nil {
+		return stageError(StageSave, err)
+	}
+
+	p.record(outputPath, view.entry)
+	return nil
+}
+
+// outputPathFor returns the path of the HTML file generated for the markdown
+// file at path
+func (p *Parser) outputPathFor(path string) (string, error) {
+	relPath, err := filepath.Rel(p.RootPath, path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(p.OutputPath, strings.TrimSuffix(relPath, filepath.Ext(relPath))+".html"), nil
+}
+
+// routeFor returns the site route of the output generated for a markdown
+// file or directory
+func (p *Parser) routeFor(path string) string {
+	relPath, err := filepath.Rel(p.RootPath, path)
+	if err != nil || relPath == "." {
+		return "/"
+	}
+
+	route := "/" + filepath.ToSlash(relPath)
+	if !p.isMarkdownFile(path) {
+		return route + "/"
+	}
+
+	route = strings.TrimSuffix(route, filepath.Ext(route))
+	if strings.HasSuffix(route, "/index") {
+		return strings.TrimSuffix(route, "index")
+	}
+	return route
+}
+
+// BasePath returns the path of a base URL, like /eng/docs for
+// https://intranet/eng/docs/, or empty for the root
+func BasePath(baseURL string) string {
+	if u, err := url.Parse(baseURL); err == nil {
+		baseURL = u.Path
+	}
+
+	baseURL = strings.Trim(baseURL, "/")
+	if baseURL == "" {
+		return ""
+	}
+	return "/" + baseURL
+}
+
+// isOutputPath reports whether path is the output directory, or the
+// directory that holds it with the other builds
+func (p *Parser) isOutputPath(path string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+
+	for _, output := range []string{p.OutputPath, p.outputRoot} {
+		if absOutput, err := filepath.Abs(output); err == nil && absPath == absOutput {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *Parser) Save(html, outputFilePath string) error {
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 type mockMarkdownParser struct{}
@@ -88,6 +89,174 @@ func TestGenerate(t *testing.T) {
 	}
 }
 
+func TestGenerateWithFrontMatter(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	markdown := "---\ntitle: Front Matter Title\ndescription: A page with metadata\n---\n# Hello World\n"
+	if err := os.WriteFile(filepath.Join(rootDir, "test.md"), []byte(markdown), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	html, err := os.ReadFile(filepath.Join(outputDir, "test.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"<title>Front Matter Title</title>",
+		`<meta name="description" content="A page with metadata" />`,
+		"<h1 id=\"hello-world\">Hello World</h1>",
+	}
+	for _, e := range expected {
+		if !strings.Contains(string(html), e) {
+			t.Errorf("Expected HTML to contain '%s', but it didn't", e)
+		}
+	}
+
+	if strings.Contains(string(html), "title: Front Matter Title") {
+		t.Error("Expected front matter to be stripped from the HTML")
+	}
+}
+
+func TestGenerateTitleFromHeading(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "platform"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "platform", "introduction.md"), "Some text\n\n## Overview\n\n# Introduction to the Platform\n\n# Second")
+	writeFile(t, filepath.Join(rootDir, "platform", "plain.md"), "## Only a subheading")
+	writeFile(t, filepath.Join(rootDir, "platform", "styled.md"), "# Intro to the *Platform* with `make`, [the setup](setup.md) and https://example.com\n\n## **Bold** heading")
+	writeFile(t, filepath.Join(rootDir, "platform", MetaFile), "title: The Platform\n")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithFlatNav(true))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	page := filepath.Join(outputDir, "platform", "introduction.html")
+	expectContains(t, page, "<title>Introduction to the Platform</title>")
+	expectContains(t, page, `<a href="/platform/introduction"> Introduction to the Platform</a>`)
+
+	// Emphasis, code spans and links are part of the title
+	styled := filepath.Join(outputDir, "platform", "styled.html")
+	expectContains(t, styled, "<title>Intro to the Platform with make, the setup and https://example.com</title>")
+	expectContains(t, page, `<a href="/platform/styled"> Intro to the Platform with make, the setup and https://example.com</a>`)
+	expectContains(t, styled, `<a href="#bold-heading">Bold heading</a>`)
+
+	// Without a level 1 heading the name is used
+	expectContains(t, filepath.Join(outputDir, "platform", "plain.html"), "<title>plain</title>")
+
+	expectContains(t, filepath.Join(outputDir, "platform", "index.html"), "<title>Index of The Platform</title>")
+}
+
+func TestGenerateSkipsUnpublished(t *testing.T) {
+	rootDir := t.TempDir()
+
+	files := map[string]string{
+		"published.md": "# Published",
+		"draft.md":     "---\ndraft: true\n---\n# Draft",
+		"future.md":    "---\npublishDate: 2030-01-01\n---\n# Future",
+		"expired.md":   "---\nexpiryDate: 2020-01-01\n---\n# Expired",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(rootDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		opts     []Option
+		expected []string
+		missing  []string
+	}{
+		{
+			name:     "default",
+			expected: []string{"published"},
+			missing:  []string{"draft", "future", "expired"},
+		},
+		{
+			name:     "drafts",
+			opts:     []Option{WithDrafts(true)},
+			expected: []string{"published", "draft"},
+			missing:  []string{"future", "expired"},
+		},
+		{
+			name:     "future",
+			opts:     []Option{WithFuture(true)},
+			expected: []string{"published", "future"},
+			missing:  []string{"draft", "expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputDir := t.TempDir()
+
+			opts := append([]Option{WithRootPath(rootDir), WithOutputPath(outputDir)}, tt.opts...)
+			p := New(NewGoldmarkParser(), opts...)
+			p.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+			if err := p.Generate(); err != nil {
+				t.Fatal(err)
+			}
+
+			index, err := os.ReadFile(filepath.Join(outputDir, "index.html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, name := range tt.expected {
+				if _, err := os.Stat(filepath.Join(outputDir, name+".html")); err != nil {
+					t.Errorf("Expected '%s.html' to be generated, but it wasn't", name)
+				}
+				if !strings.Contains(string(index), `href="/`+name+`"`) {
+					t.Errorf("Expected index to link to '%s', but it didn't", name)
+				}
+			}
+
+			for _, name := range tt.missing {
+				if _, err := os.Stat(filepath.Join(outputDir, name+".html")); err == nil {
+					t.Errorf("Expected '%s.html' not to be generated, but it was", name)
+				}
+				if strings.Contains(string(index), `href="/`+name+`"`) {
+					t.Errorf("Expected index not to link to '%s', but it did", name)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateLiveReload(t *testing.T) {
+	rootDir := t.TempDir()
+	writeFile(t, filepath.Join(rootDir, "test.md"), "# Hello World")
+
+	// Without live reload there is no client script
+	outputDir := t.TempDir()
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expectNotContains(t, filepath.Join(outputDir, "test.html"), "livereload.js")
+
+	// With live reload the client script is added to every page
+	outputDir = t.TempDir()
+	p = New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithLiveReload(true))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	expectContains(t, filepath.Join(outputDir, "test.html"), `<script src="/static/js/livereload.js" data-base-path=""></script>`)
+	expectContains(t, filepath.Join(outputDir, "index.html"), `<script src="/static/js/livereload.js" data-base-path=""></script>`)
+}
+
 func TestIsIgnored(t *testing.T) {
 	p := &Parser{}
 	if !p.isIgnored(".hidden") {
@@ -157,3 +326,160 @@ func TestGetDirectoryListing(t *testing.T) {
 		}
 	}
 }
+
+func TestGetDirectoryListingURLs(t *testing.T) {
+	tempDir := t.TempDir()
+	rootDir := filepath.Join(tempDir, "docs")
+
+	if err := os.MkdirAll(filepath.Join(rootDir, "guide", "advanced"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "intro.md"), "# Intro")
+	writeFile(t, filepath.Join(rootDir, "guide", "index.md"), "# Guide")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.markdown"), "# Setup")
+	writeFile(t, filepath.Join(rootDir, "guide", "advanced", "tuning.md"), "# Tuning")
+
+	// Run from the temporary directory, so the root can be relative
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tempDir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	tests := []struct {
+		name     string
+		root     string
+		dir      string
+		expected map[string]string
+	}{
+		{
+			name: "absolute root",
+			root: rootDir,
+			dir:  rootDir,
+			expected: map[string]string{
+				"guide":    "/guide/",
+				"intro.md": "/intro",
+			},
+		},
+		{
+			name: "relative root",
+			root: "docs",
+			dir:  filepath.Join("docs", "guide"),
+			expected: map[string]string{
+				"..":             "/",
+				"advanced":       "/guide/advanced/",
+				"index.md":       "/guide/",
+				"setup.markdown": "/guide/setup",
+			},
+		},
+		{
+			name: "nested directory",
+			root: "./docs/",
+			dir:  filepath.Join("docs", "guide", "advanced"),
+			expected: map[string]string{
+				"..":        "/guide/",
+				"tuning.md": "/guide/advanced/tuning",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(&mockMarkdownParser{}, WithRootPath(tt.root))
+
+			entries, err := p.getDirectoryListing(tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("Expected %d entries, but got %d: %v", len(tt.expected), len(entries), entries)
+			}
+
+			for _, entry := range entries {
+				if entry.URL != tt.expected[entry.Name] {
+					t.Errorf("Expected URL of '%s' to be '%s', but got '%s'", entry.Name, tt.expected[entry.Name], entry.URL)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateSidebarLinks(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "intro.md"), "# Intro")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithFlatNav(true))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectContains(t, filepath.Join(outputDir, "intro.html"), `<a href="/guide/">`)
+	expectContains(t, filepath.Join(outputDir, "intro.html"), `<a href="/intro">`)
+	expectContains(t, filepath.Join(outputDir, "guide", "setup.html"), `<a href="/">`)
+	expectContains(t, filepath.Join(outputDir, "guide", "setup.html"), `<a href="/guide/setup">`)
+	expectNotContains(t, filepath.Join(outputDir, "intro.html"), rootDir)
+}
+
+func TestGenerateBaseURL(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "intro.md"), "# Intro\n\n[setup](guide/setup.md) [home](/) ![logo](/img/logo.png) [top](#intro)")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithBaseURL("https://intranet/eng/docs/"), WithLiveReload(true))
+	if p.BasePath != "/eng/docs" {
+		t.Errorf("Expected base path '/eng/docs', but got '%s'", p.BasePath)
+	}
+
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	intro := filepath.Join(outputDir, "intro.html")
+	for _, expected := range []string{
+		`href="/eng/docs/static/css/main.css"`,
+		`href="/eng/docs/static/img/feather-sprite.svg#menu"`,
+		`<a href="/eng/docs/guide/">`,
+		`href="/eng/docs/intro"`,
+		`<a href="guide/setup">setup</a>`,
+		`<a href="/eng/docs/">home</a>`,
+		`src="/eng/docs/img/logo.png"`,
+		`<a href="#intro">top</a>`,
+		`src="/eng/docs/static/js/livereload.js" data-base-path="/eng/docs"`,
+	} {
+		expectContains(t, intro, expected)
+	}
+
+	expectContains(t, filepath.Join(outputDir, "guide", "setup.html"), `href="/eng/docs/"`)
+}
+
+func TestBasePath(t *testing.T) {
+	tests := map[string]string{
+		"":                           "",
+		"/":                          "",
+		"https://intranet/":          "",
+		"https://intranet/eng/docs/": "/eng/docs",
+		"/eng/docs":                  "/eng/docs",
+		"eng/docs/":                  "/eng/docs",
+	}
+
+	for baseURL, expected := range tests {
+		if actual := BasePath(baseURL); actual != expected {
+			t.Errorf("Expected base path of '%s' to be '%s', but got '%s'", baseURL, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package parser
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jpbruinsslot/mdex/acl"
+	"github.com/jpbruinsslot/mdex/watcher"
+)
+
+// ErrNotFound is returned by Render for routes without a published page
+var ErrNotFound = errors.New("page not found")
+
+// refreshInterval is how often Render checks the tree for changes at most
+const refreshInterval = time.Second
+
+// maxAccessRenderers is how many renderers for other accesses are kept at
+// most, the least recently used one is dropped to make room for a new one
+const maxAccessRenderers = 64
+
+// Renderer renders the pages of the site on request, instead of generating
+// the whole site up front. Rendered pages are cached until their source or
+// the navigation of the site changes.
+type Renderer struct {
+	parser  *Parser
+	watcher *watcher.Watcher
+
+	// mu guards the listings and navigation of the parser, which are read
+	// again when the tree changes
+	mu      sync.RWMutex
+	checked time.Time
+
+	cache   map[string]cachedPage
+	cacheMu sync.Mutex
+
+	// accesses are the renderers of the site as it is seen with other
+	// accesses, keyed by access
+	accesses   map[string]*accessRenderer
+	accessesMu sync.Mutex
+	// uses counts the requests for other accesses, to find the least
+	// recently used renderer
+	uses uint64
+}
+
+// accessRenderer is the renderer for another access. It's created once, by
+// the first request for the access, while other requests wait for it.
+type accessRenderer struct {
+	once     sync.Once
+	renderer *Renderer
+	err      error
+	used     uint64
+}
+
+// cachedPage is a rendered page, with the inputs it was rendered from
+type cachedPage struct {
+	entry   ManifestEntry
+	navHash string
+	// modTime and size of the source, so unchanged sources aren't read
+	modTime time.Time
+	size    int64
+	html    []byte
+}
+
+// NewRenderer reads the listings of the site and builds its navigation, so
+// pages can be rendered on request
+func NewRenderer(p *Parser) (*Renderer, error) {
+	r := &Renderer{
+		parser:   p,
+		watcher:  watcher.New(p.RootPath, watcher.WithSkip(p.skipWatch)),
+		cache:    make(map[string]cachedPage),
+		accesses: make(map[string]*accessRenderer),
+	}
+
+	if _, err := r.watcher.Poll(); err != nil {
+		return nil, err
+	}
+	r.checked = time.Now()
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// load reads the listings of all directories and builds the navigation.
+// Directories that can't be listed are logged, their pages fail when they
+// are requested.
+func (r *Renderer) load() error {
+	p := r.parser
+
+	plan, errs, err := p.discover(p.RootPath)
+	if err != nil {
+		return err
+	}
+
+	p.forgetListings(p.RootPath)
+	errs = append(errs, p.readListings(plan.dirs)...)
+	for _, err := range errs {
+		p.Logger.Error("Failed to read listing", "error", err)
+	}
+
+	// Forget the pages that were removed
+	r.cacheMu.Lock()
+	defer r.cacheMu.Unlock()
+
+	for path := range r.cache {
+		if _, err := os.Stat(path); err != nil {
+			delete(r.cache, path)
+		}
+	}
+
+	return nil
+}
+
+// refresh reads the listings again when the tree changed since it was last
+// checked
+func (r *Renderer) refresh() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checked) < refreshInterval {
+		return nil
+	}
+
+	events, err := r.watcher.Poll()
+	if err != nil {
+		return err
+	}
+	r.checked = time.Now()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	r.parser.Logger.Info("Change detected", "changes", len(events))
+	return r.load()
+}
+
+// Render returns the page at a site route, like /guide/setup, rendered with
+// the same templates as Generate. It returns ErrNotFound when there is no
+// published page at the route.
+func (r *Renderer) Render(route string) ([]byte, error) {
+	if err := r.refresh(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p := r.parser
+
+	path, ok := p.sourceFor(route)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	// Directories are rendered from their index page, if they have one
+	isDir := !p.isMarkdownFile(path)
+	if !p.isAllowed(path, isDir) {
+		return nil, ErrNotFound
+	}
+	source := path
+	if isDir {
+		source, _ = p.indexPageFor(path)
+	}
+
+	var modTime time.Time
+	size := int64(-1)
+	if source != "" {
+		info, err := os.Stat(source)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		} else if err != nil {
+			return nil, err
+		}
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	r.cacheMu.Lock()
+	cached, ok := r.cache[path]
+	r.cacheMu.Unlock()
+
+	if ok && cached.navHash == p.navHash && cached.modTime.Equal(modTime) && cached.size == size {
+		return cached.html, nil
+	}
+
+	view, err := r.prepare(path, isDir)
+	if err != nil {
+		return nil, asBuildError(path, "", err)
+	}
+	if view == nil {
+		return nil, ErrNotFound
+	}
+
+	// Sources that were touched, but didn't change, aren't rendered again
+	html := cached.html
+	if !ok || cached.navHash != p.navHash || cached.entry != view.entry {
+		p.Logger.Info("Rendering", "file", path)
+
+		rendered, err := p.renderView(view)
+		if err != nil {
+			return nil, asBuildError(path, "", err)
+		}
+		html = []byte(rendered)
+	}
+
+	r.cacheMu.Lock()
+	r.cache[path] = cachedPage{
+		entry:   view.entry,
+		navHash: p.navHash,
+		modTime: modTime,
+		size:    size,
+		html:    html,
+	}
+	r.cacheMu.Unlock()
+
+	return html, nil
+}
+
+// RenderFor returns the page at a site route as the user sees it, with the
+// pages the user can't access left out of the navigation. It returns
+// ErrNotFound when the user can't access the page itself. Users with the same
+// access share a renderer, which is created on their first request.
+func (r *Renderer) RenderFor(route string, user acl.User) ([]byte, error) {
+	a := r.parser.Access.ACL()
+	if a == nil {
+		return r.Render(route)
+	}
+
+	access := a.Access(user)
+	if access.Key() == r.parser.Access.Key() {
+		return r.Render(route)
+	}
+
+	renderer, err := r.forAccess(access)
+	if err != nil {
+		return nil, err
+	}
+
+	return renderer.Render(route)
+}
+
+// forAccess returns the renderer for another access. The renderer is created
+// outside the lock, so creating it doesn't hold up the requests of others.
+func (r *Renderer) forAccess(access acl.Access) (*Renderer, error) {
+	key := access.Key()
+
+	r.accessesMu.Lock()
+	entry, ok := r.accesses[key]
+	if !ok {
+		if len(r.accesses) >= maxAccessRenderers {
+			r.evictAccess()
+		}
+		entry = &accessRenderer{}
+		r.accesses[key] = entry
+	}
+	r.uses++
+	entry.used = r.uses
+	r.accessesMu.Unlock()
+
+	entry.once.Do(func() {
+		entry.renderer, entry.err = NewRenderer(r.parser.withAccess(access))
+	})
+	if entry.err != nil {
+		// Drop the failed renderer, so the next request tries again
+		r.accessesMu.Lock()
+		if r.accesses[key] == entry {
+			delete(r.accesses, key)
+		}
+		r.accessesMu.Unlock()
+		return nil, entry.err
+	}
+
+	return entry.renderer, nil
+}
+
+// evictAccess drops the least recently used renderer for another access. The
+// caller must hold accessesMu.
+func (r *Renderer) evictAccess() {
+	var oldest string
+	var used uint64
+	for key, entry := range r.accesses {
+		if used == 0 || entry.used < used {
+			oldest, used = key, entry.used
+		}
+	}
+	delete(r.accesses, oldest)
+}
+
+// prepare reads the page at path, or the index of the directory at path
+func (r *Renderer) prepare(path string, isDir bool) (*pageView, error) {
+	p := r.parser
+
+	if !isDir {
+		markdown, err := os.ReadFile(path)
+		if err != nil {
+			return nil, stageError(StageRead, err)
+		}
+		return p.preparePage(path, markdown)
+	}
+
+	files, ok := p.listing(path)
+	if !ok {
+		var err error
+		files, err = p.getDirectoryListing(path)
+		if err != nil {
+			return nil, stageError(StageListing, err)
+		}
+	}
+	return p.prepareIndex(path, files)
+}
+
+// Asset returns the file at a site route that is served as it is, like an
+// image next to the markdown files, or an index.html in a directory
+func (r *Renderer) Asset(route string) (string, bool) {
+	p := r.parser
+
+	if strings.HasSuffix(route, "/") {
+		route += "index.html"
+	}
+
+	path, ok := p.pathFor(route)
+	if !ok || !p.isAsset(path) {
+		return "", false
+	}
+	if _, conflict := p.assetConflict(path); conflict {
+		return "", false
+	}
+
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", false
+	}
+	return path, true
+}
+
+// sourceFor returns the markdown file of the page at a site route, or the
+// directory of a directory index
+func (p *Parser) sourceFor(route string) (string, bool) {
+	isDir := strings.HasSuffix(route, "/")
+
+	path, ok := p.pathFor(route)
+	if !ok {
+		return "", false
+	}
+
+	// Generated pages can be requested with their extension as well, and
+	// directory indexes by their index page
+	path = strings.TrimSuffix(path, ".html")
+	if filepath.Base(path) == "index" {
+		path = filepath.Dir(path)
+		isDir = true
+	}
+
+	if isDir {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path, true
+		}
+		return "", false
+	}
+
+	for _, ext := range []string{".md", ".markdown", ".mkd"} {
+		if info, err := os.Stat(path + ext); err == nil && !info.IsDir() {
+			return path + ext, true
+		}
+	}
+	return "", false
+}
+
+// pathFor returns the path under the root path of a site route. Hidden and
+// ignored files, and the output directory, are not part of the site.
+func (p *Parser) pathFor(route string) (string, bool) {
+	route = path.Clean("/" + route)
+
+	for _, name := range strings.Split(route, "/") {
+		if name != "" && p.isIgnored(name) {
+			return "", false
+		}
+	}
+
+	root := filepath.Clean(p.RootPath)
+	path := filepath.Join(root, filepath.FromSlash(route))
+	for dir := path; dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if p.isOutputPath(dir) {
+			return "", false
+		}
+	}
+
+	return path, true
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	rootDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "index.md"), "# Home\n\nWelcome.")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup\n\nRun make.")
+	writeFile(t, filepath.Join(rootDir, "draft.md"), "---\ndraft: true\n---\n# Draft")
+	writeFile(t, filepath.Join(rootDir, "_notes.md"), "# Notes")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithBaseURL("/docs"))
+	r, err := NewRenderer(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"/":                 "<p>Welcome.</p>",
+		"/index":            "<p>Welcome.</p>",
+		"/guide/setup":      "<p>Run make.</p>",
+		"/guide/setup.html": "<p>Run make.</p>",
+		"/guide/":           "Index of guide",
+	}
+	for route, expected := range tests {
+		page, err := r.Render(route)
+		if err != nil {
+			t.Errorf("Expected %s to render, but got %v", route, err)
+			continue
+		}
+		if !strings.Contains(string(page), expected) {
+			t.Errorf("Expected %s to contain '%s'", route, expected)
+		}
+	}
+
+	// Pages use the navigation of the whole site, under the base path
+	page, err := r.Render("/guide/setup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), `href="/docs/guide/"`) {
+		t.Error("Expected the navigation to link to /docs/guide/")
+	}
+
+	for _, route := range []string{"/draft", "/_notes", "/missing", "/guide", "/../etc/passwd"} {
+		if _, err := r.Render(route); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected %s to be not found, but got %v", route, err)
+		}
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	rootDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# Alpha\n\nFirst.")
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# Bravo")
+
+	r, err := NewRenderer(New(NewGoldmarkParser(), WithRootPath(rootDir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := r.Render("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unchanged pages come from the cache
+	second, err := r.Render("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &first[0] != &second[0] {
+		t.Error("Expected the cached page to be returned")
+	}
+
+	// A touched page that didn't change isn't rendered again
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(rootDir, "a.md"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	third, err := r.Render("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &first[0] != &third[0] {
+		t.Error("Expected the page to be reused when its content didn't change")
+	}
+
+	// Changed pages are rendered again
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# Alpha\n\nSecond.")
+	page, err := r.Render("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "<p>Second.</p>") {
+		t.Error("Expected the changed page to be rendered again")
+	}
+
+	// A changed title of another page changes the navigation
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# Charlie")
+	r.checked = time.Time{}
+	page, err = r.Render("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "Charlie") {
+		t.Error("Expected the navigation to show the new title")
+	}
+}
+
+func TestRenderAsset(t *testing.T) {
+	rootDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "legacy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "logo.png"), "png")
+	writeFile(t, filepath.Join(rootDir, "legacy", "index.html"), "<html></html>")
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# Alpha")
+	writeFile(t, filepath.Join(rootDir, "a.html"), "<html></html>")
+
+	r, err := NewRenderer(New(NewGoldmarkParser(), WithRootPath(rootDir)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"/logo.png": filepath.Join(rootDir, "logo.png"),
+		"/legacy/":  filepath.Join(rootDir, "legacy", "index.html"),
+	}
+	for route, expected := range tests {
+		if path, ok := r.Asset(route); !ok || path != expected {
+			t.Errorf("Expected asset %s for %s, but got '%s'", expected, route, path)
+		}
+	}
+
+	// Markdown files are rendered, not served
+	if _, ok := r.Asset("/a.md"); ok {
+		t.Error("Expected markdown files not to be served as assets")
+	}
+	if _, ok := r.Asset("/a.html"); ok {
+		t.Error("Expected the page of a.md to be rendered instead of a.html")
+	}
+}
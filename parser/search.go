@@ -0,0 +1,329 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jpbruinsslot/mdex/search"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+// SearchDir is the directory in the output that holds the search index. The
+// index is read by the search script in the browser, so it works without a
+// server.
+const SearchDir = "_search"
+
+const (
+	searchVersion = 1
+	// searchChunkSize is the number of documents per file
+	searchChunkSize = 200
+	// searchPrefixLength is the number of characters of the terms the
+	// index is sharded by
+	searchPrefixLength  = 2
+	searchExcerptLength = 160
+)
+
+// Weights of the terms in the parts of a page
+const (
+	titleWeight   = 10
+	headingWeight = 5
+	tagWeight     = 5
+	bodyWeight    = 1
+)
+
+// TextExtractor can be implemented by a MarkdownParser to report the plain
+// text of a page for the search index. Parsers that don't implement it get
+// the markdown itself indexed.
+type TextExtractor interface {
+	ExtractText(markdown []byte) (string, error)
+}
+
+// searchDoc is a page in the search index
+type searchDoc struct {
+	Title string `json:"title"`
+	// URL is the site-relative URL of the page, the search script adds the
+	// base path
+	URL      string   `json:"url"`
+	Excerpt  string   `json:"excerpt,omitempty"`
+	Headings []string `json:"headings,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	// terms are the weighted frequencies of the terms in the page
+	terms map[string]int
+}
+
+// searchIndex is the entry point of the search index, it lists the shards
+// of terms and how the documents are split in chunks
+type searchIndex struct {
+	Version   int      `json:"version"`
+	Pages     int      `json:"pages"`
+	ChunkSize int      `json:"chunkSize"`
+	Shards    []string `json:"shards"`
+}
+
+// indexForSearch adds a page to the search index, or updates it
+func (p *Parser) indexForSearch(path string, data TemplateData, body []byte) error {
+	if !p.Search {
+		return nil
+	}
+
+	toc, err := p.Parser.ExtractTOC(body)
+	if err != nil {
+		return stageError(StageTOC, err)
+	}
+
+	content := string(body)
+	if extractor, ok := p.Parser.(TextExtractor); ok {
+		content, err = extractor.ExtractText(body)
+		if err != nil {
+			return stageError(StageSearch, err)
+		}
+	}
+
+	doc := searchDoc{
+		Title:   data.Title,
+		URL:     data.URL,
+		Excerpt: excerpt(content, searchExcerptLength),
+		Tags:    data.Tags,
+		terms:   make(map[string]int),
+	}
+
+	for _, entry := range toc {
+		// The first heading usually repeats the title
+		if entry.Title == "" || entry.Title == doc.Title {
+			continue
+		}
+		doc.Headings = append(doc.Headings, entry.Title)
+		addTerms(doc.terms, entry.Title, headingWeight)
+	}
+
+	addTerms(doc.terms, doc.Title, titleWeight)
+	for _, tag := range doc.Tags {
+		addTerms(doc.terms, tag, tagWeight)
+	}
+	addTerms(doc.terms, content, bodyWeight)
+
+	p.searchMu.Lock()
+	defer p.searchMu.Unlock()
+
+	p.searchDocs[path] = doc
+	return nil
+}
+
+// forgetSearch removes a page from the search index
+func (p *Parser) forgetSearch(path string) {
+	p.searchMu.Lock()
+	defer p.searchMu.Unlock()
+
+	delete(p.searchDocs, path)
+}
+
+// forgetSearchTree removes the pages of a directory and its subdirectories
+// from the search index
+func (p *Parser) forgetSearchTree(dir string) {
+	p.searchMu.Lock()
+	defer p.searchMu.Unlock()
+
+	for path := range p.searchDocs {
+		if isWithin(path, []string{dir}) {
+			delete(p.searchDocs, path)
+		}
+	}
+}
+
+// writeSearchIndex writes the search index to SearchDir in the output. Terms
+// are sharded by their first characters and documents are split in chunks,
+// so the browser only loads what a query needs. Files that didn't change are
+// left as they are.
+func (p *Parser) writeSearchIndex() error {
+	dir := filepath.Join(p.OutputPath, SearchDir)
+	if !p.Search {
+		return os.RemoveAll(dir)
+	}
+
+	p.searchMu.Lock()
+	docs := make([]searchDoc, 0, len(p.searchDocs))
+	for _, doc := range p.searchDocs {
+		docs = append(docs, doc)
+	}
+	p.searchMu.Unlock()
+
+	slices.SortFunc(docs, func(a, b searchDoc) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+
+	// Postings are pairs of document number and weight
+	shards := make(map[string]map[string][]int)
+	for id, doc := range docs {
+		for _, term := range sortedKeys(doc.terms) {
+			name := shardName(term)
+			if shards[name] == nil {
+				shards[name] = make(map[string][]int)
+			}
+			shards[name][term] = append(shards[name][term], id, doc.terms[term])
+		}
+	}
+
+	files := make(map[string]any)
+	for name, terms := range shards {
+		files[filepath.Join("terms", name+".json")] = terms
+	}
+	for start := 0; start < len(docs); start += searchChunkSize {
+		chunk := docs[start:min(start+searchChunkSize, len(docs))]
+		files[filepath.Join("docs", strconv.Itoa(start/searchChunkSize)+".json")] = chunk
+	}
+	files["index.json"] = searchIndex{
+		Version:   searchVersion,
+		Pages:     len(docs),
+		ChunkSize: searchChunkSize,
+		Shards:    sortedKeys(shards),
+	}
+
+	for _, name := range sortedKeys(files) {
+		data, err := json.Marshal(files[name])
+		if err != nil {
+			return err
+		}
+		if err := writeFileIfChanged(filepath.Join(dir, name), data); err != nil {
+			return err
+		}
+	}
+
+	// Remove the shards and chunks that are no longer part of the index
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := files[relPath]; ok {
+			return nil
+		}
+
+		// Compressed copies of the files are left to precompress, which
+		// recompresses or removes them when they are out of date
+		if _, ok := files[strings.TrimSuffix(relPath, ".gz")]; ok {
+			key, err := p.manifestKey(path)
+			if err != nil {
+				return err
+			}
+			if entry, _ := p.manifest.get(key); entry.Precompressed {
+				return nil
+			}
+		}
+
+		return os.Remove(path)
+	})
+}
+
+// shardName returns the name of the shard of a term, which is its prefix
+// encoded as hex, so it is a valid file name for any script
+func shardName(term string) string {
+	prefix := term
+	n := 0
+	for i := range term {
+		if n == searchPrefixLength {
+			prefix = term[:i]
+			break
+		}
+		n++
+	}
+	return hex.EncodeToString([]byte(prefix))
+}
+
+// addTerms adds the terms of s with the given weight
+func addTerms(terms map[string]int, s string, weight int) {
+	for _, term := range search.Tokenize(s) {
+		terms[term] += weight
+	}
+}
+
+// excerpt returns the start of the text, cut at a word boundary
+func excerpt(s string, length int) string {
+	var b strings.Builder
+	n := 0
+	for _, word := range strings.Fields(s) {
+		runes := utf8.RuneCountInString(word)
+		if n > 0 && n+1+runes > length {
+			b.WriteString(" …")
+			break
+		}
+		if n > 0 {
+			b.WriteByte(' ')
+			n++
+		}
+		b.WriteString(word)
+		n += runes
+	}
+	return b.String()
+}
+
+// writeFileIfChanged writes data to path, unless the file already holds it
+func writeFileIfChanged(path string, data []byte) error {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// ExtractText returns the plain text of the markdown, with a line break
+// after every block
+func (p *GoldmarkParser) ExtractText(markdown []byte) (string, error) {
+	doc := p.sourceParser.Parse(text.NewReader(markdown))
+
+	var b strings.Builder
+
+	err := ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			if n.Type() == ast.TypeBlock {
+				b.WriteByte('\n')
+			}
+			return ast.WalkContinue, nil
+		}
+
+		switch n := n.(type) {
+		case *ast.Text:
+			b.Write(n.Segment.Value(markdown))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		case *ast.String:
+			b.Write(n.Value)
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			lines := n.Lines()
+			for i := range lines.Len() {
+				line := lines.At(i)
+				b.Write(line.Value(markdown))
+			}
+		case *ast.RawHTML, *ast.HTMLBlock:
+			return ast.WalkSkipChildren, nil
+		}
+
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,174 @@
+package parser
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGenerateSearchIndex(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "intro.md"), "---\ntags: [platform]\n---\n# Introduction\n\nWelcome to the **platform**.\n\n## Getting started")
+	writeFile(t, filepath.Join(rootDir, "guide", "index.md"), "# Guide\n\nHow to use it.")
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup\n\n```sh\nmake install\n```")
+	writeFile(t, filepath.Join(rootDir, "draft.md"), "---\ndraft: true\n---\n# Draft")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithBaseURL("/docs"))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var index searchIndex
+	readJSON(t, filepath.Join(outputDir, SearchDir, "index.json"), &index)
+	if index.Pages != 3 {
+		t.Errorf("Expected 3 pages in the index, but got %d", index.Pages)
+	}
+
+	var docs []searchDoc
+	readJSON(t, filepath.Join(outputDir, SearchDir, "docs", "0.json"), &docs)
+
+	// Documents are ordered by URL, which is site-relative
+	expected := []searchDoc{
+		{Title: "Guide", URL: "/guide/", Excerpt: "Guide How to use it."},
+		{Title: "Setup", URL: "/guide/setup", Excerpt: "Setup make install"},
+		{
+			Title:    "Introduction",
+			URL:      "/intro",
+			Excerpt:  "Introduction Welcome to the platform. Getting started",
+			Headings: []string{"Getting started"},
+			Tags:     []string{"platform"},
+		},
+	}
+	if len(docs) != len(expected) {
+		t.Fatalf("Expected %d documents, but got %d: %+v", len(expected), len(docs), docs)
+	}
+	for i := range expected {
+		if docs[i].Title != expected[i].Title || docs[i].URL != expected[i].URL || docs[i].Excerpt != expected[i].Excerpt ||
+			!slices.Equal(docs[i].Headings, expected[i].Headings) || !slices.Equal(docs[i].Tags, expected[i].Tags) {
+			t.Errorf("Expected document %+v, but got %+v", expected[i], docs[i])
+		}
+	}
+
+	// Terms are weighted by where they appear on the page
+	var shard map[string][]int
+	readJSON(t, filepath.Join(outputDir, SearchDir, "terms", shardName("platform")+".json"), &shard)
+	if postings := shard["platform"]; !slices.Equal(postings, []int{2, tagWeight + bodyWeight}) {
+		t.Errorf("Expected postings [2 %d] for 'platform', but got %v", tagWeight+bodyWeight, postings)
+	}
+
+	if !slices.Contains(index.Shards, shardName("install")) {
+		t.Errorf("Expected a shard for 'install' in %v", index.Shards)
+	}
+
+	expectContains(t, filepath.Join(outputDir, "intro.html"), `<script src="/docs/static/js/search.js" data-base-path="/docs"></script>`)
+	if _, err := os.Stat(filepath.Join(outputDir, StaticDir, "js", "search.js")); err != nil {
+		t.Errorf("Expected the search script to be written to the output: %v", err)
+	}
+}
+
+func TestRegenerateSearchIndex(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# Alpha")
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# Bravo")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	bravo := filepath.Join(outputDir, SearchDir, "terms", shardName("bravo")+".json")
+	if _, err := os.Stat(bravo); err != nil {
+		t.Fatalf("Expected a shard for 'bravo': %v", err)
+	}
+
+	// Removed pages and the shards only they used are removed
+	if err := os.Remove(filepath.Join(rootDir, "b.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Regenerate([]string{filepath.Join(rootDir, "b.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	var index searchIndex
+	readJSON(t, filepath.Join(outputDir, SearchDir, "index.json"), &index)
+	if index.Pages != 1 {
+		t.Errorf("Expected 1 page in the index, but got %d", index.Pages)
+	}
+	if _, err := os.Stat(bravo); !os.IsNotExist(err) {
+		t.Error("Expected the shard for 'bravo' to be removed")
+	}
+
+	// Without search the index and the search box are left out
+	p = New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithSearch(false))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, SearchDir)); !os.IsNotExist(err) {
+		t.Error("Expected the search index to be removed")
+	}
+	expectNotContains(t, filepath.Join(outputDir, "a.html"), `id="search-input"`)
+}
+
+func TestShardName(t *testing.T) {
+	tests := map[string]string{
+		"setup": "7365",
+		"a":     "61",
+		"día":   "64c3ad",
+	}
+
+	for term, expected := range tests {
+		if name := shardName(term); name != expected {
+			t.Errorf("Expected shard '%s' for '%s', but got '%s'", expected, term, name)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	if actual := excerpt("one  two\nthree", 20); actual != "one two three" {
+		t.Errorf("Expected 'one two three', but got '%s'", actual)
+	}
+	if actual := excerpt("one two three", 8); actual != "one two …" {
+		t.Errorf("Expected 'one two …', but got '%s'", actual)
+	}
+}
+
+func readJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenerateServerSearch(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# Alpha")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithServerSearch(true))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server searches its own index, so the one of the browser is left
+	// out
+	if _, err := os.Stat(filepath.Join(outputDir, SearchDir)); !os.IsNotExist(err) {
+		t.Error("Expected no search index in the output")
+	}
+	expectContains(t, filepath.Join(outputDir, "a.html"), `action="/_mdex/search"`)
+	expectContains(t, filepath.Join(outputDir, "a.html"), `data-server-search`)
+}
@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// pageView is a page or directory index that is ready to be rendered, along
+// with the inputs it depends on
+type pageView struct {
+	// template is the name of the template the page is rendered with
+	template string
+	data     TemplateData
+	// body is the markdown without front matter, or nil for directories
+	// without an index page
+	body []byte
+	// source is the markdown file of the page, or empty for directories
+	// without an index page
+	source string
+	// entry records the inputs of the page, to tell whether it changed
+	entry ManifestEntry
+}
+
+// preparePage reads the metadata and navigation of the markdown file at path.
+// It returns nil for pages that are not published.
+func (p *Parser) preparePage(path string, markdown []byte) (*pageView, error) {
+	page, body, err := p.extractMetadata(markdown)
+	if err != nil {
+		return nil, stageError(StageMetadata, err)
+	}
+
+	if !p.isPublished(page) || !p.isAllowed(path, false) {
+		return nil, nil
+	}
+
+	parentDir := filepath.Dir(path)
+	files, ok := p.listing(parentDir)
+	if !ok {
+		files, err = p.getDirectoryListing(parentDir)
+		if err != nil {
+			return nil, stageError(StageListing, err)
+		}
+	}
+
+	name := filepath.Base(path)
+	data := p.newTemplateData(page, strings.TrimSuffix(name, filepath.Ext(name)))
+	data.URL = p.routeFor(path)
+	data.PageLinks = p.pageLinks(data.URL, data.Title)
+	data.Files = files
+
+	return &pageView{
+		template: "single",
+		data:     data,
+		body:     body,
+		source:   path,
+		entry: ManifestEntry{
+			Source:      p.relSourcePath(path),
+			SourceHash:  hashBytes(markdown),
+			Listing:     p.relSourcePath(parentDir),
+			ListingHash: p.hashNavigation(files, data.PageLinks),
+		},
+	}, nil
+}
+
+// prepareIndex reads the index page of a directory, if it has a published
+// one, and the navigation of the directory
+func (p *Parser) prepareIndex(dir string, files []FileEntry) (*pageView, error) {
+	view := &pageView{
+		template: "index",
+		entry: ManifestEntry{
+			Listing: p.relSourcePath(dir),
+		},
+	}
+
+	page := Page{Params: map[string]any{}}
+
+	// If there is an index.md file, we use that to generate the index
+	if indexMDPath, ok := p.indexPageFor(dir); ok {
+		source, err := os.ReadFile(indexMDPath)
+		if err != nil {
+			return nil, &BuildError{Path: indexMDPath, Stage: StageRead, Err: err}
+		}
+
+		indexPage, body, err := p.extractMetadata(source)
+		if err != nil {
+			return nil, &BuildError{Path: indexMDPath, Stage: StageMetadata, Err: err}
+		}
+
+		// An unpublished index.md falls back to the plain directory index
+		if p.isPublished(indexPage) {
+			page = indexPage
+			view.body = body
+			view.source = indexMDPath
+			view.entry.Source = p.relSourcePath(indexMDPath)
+			view.entry.SourceHash = hashBytes(source)
+		}
+	}
+
+	title, _ := p.dirTitle(dir)
+	if title == "" {
+		title = filepath.Base(dir)
+	}
+
+	view.data = p.newTemplateData(page, "Index of "+title)
+	view.data.URL = p.routeFor(dir)
+	view.data.PageLinks = p.pageLinks(view.data.URL, view.data.Title)
+	view.data.Files = files
+	view.data.IsIndex = true
+	view.entry.ListingHash = p.hashNavigation(files, view.data.PageLinks)
+
+	return view, nil
+}
+
+// renderView converts the markdown of a prepared page and renders it with its
+// template
+func (p *Parser) renderView(view *pageView) (string, error) {
+	data := view.data
+	data.Content = template.HTML("")
+	data.TOC = []TOCEntry{}
+	data.Nav = p.navFor(data.URL)
+
+	if view.source != "" {
+		html, err := p.Parser.Convert(view.body)
+		if err != nil {
+			return "", &BuildError{Path: view.source, Stage: StageConvert, Err: err}
+		}
+
+		toc, err := p.Parser.ExtractTOC(view.body)
+		if err != nil {
+			return "", &BuildError{Path: view.source, Stage: StageTOC, Err: err}
+		}
+
+		data.Content = template.HTML(html)
+		data.TOC = toc
+	}
+
+	rendered, err := p.renderTemplate(view.template, data)
+	if err != nil {
+		return "", stageError(StageRender, err)
+	}
+
+	return rendered, nil
+}
@@ -0,0 +1,350 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jpbruinsslot/mdex/watcher"
+)
+
+// Watch monitors the root path and regenerates the outputs affected by
+// changes until the context is cancelled. Generate should have been called
+// before, so the outputs are up to date when watching starts.
+func (p *Parser) Watch(ctx context.Context, opts ...watcher.Option) error {
+	opts = append([]watcher.Option{watcher.WithSkip(p.skipWatch), watcher.WithLogger(p.Logger)}, opts...)
+	w := watcher.New(p.RootPath, opts...)
+
+	p.Logger.Info("Watching for changes", "root", p.RootPath)
+
+	return w.Run(ctx, func(events []watcher.Event) {
+		paths := make([]string, 0, len(events))
+		for _, event := range events {
+			p.Logger.Info("Change detected", "file", event.Path, "op", event.Op)
+			paths = append(paths, event.Path)
+		}
+
+		start := time.Now()
+		changes, err := p.Regenerate(paths)
+		if err != nil {
+			// Outputs that could be generated are still reloaded
+			p.Logger.Error("Regenerating failed", "error", err)
+		} else {
+			p.Logger.Info("Regenerated", "changes", len(paths), "duration", time.Since(start))
+		}
+
+		if p.OnRegenerate != nil {
+			p.OnRegenerate(changes)
+		}
+	})
+}
+
+// Changes describes the outputs written by Regenerate
+type Changes struct {
+	// Routes are the site routes of the pages that were rendered again
+	Routes []string
+	// Structural is set when the structure of the site changed, which
+	// affects the navigation of other pages
+	Structural bool
+	// All is set when every page is affected, because the navigation tree
+	// changed, or an asset changed that any page can refer to
+	All bool
+}
+
+func (p *Parser) skipWatch(path string, d fs.DirEntry) bool {
+	if d.Name() == MetaFile {
+		return false
+	}
+	return p.isOutputPath(path) || p.isIgnored(d.Name())
+}
+
+// Regenerate updates the outputs affected by changes to the given source
+// paths. Changed pages are rendered again, outputs of removed sources are
+// deleted, and when the listing of a directory changes its index and all of
+// its pages are rendered again, since their sidebar changed. So are pages
+// whose breadcrumbs or previous and next pages changed.
+func (p *Parser) Regenerate(paths []string) (Changes, error) {
+	var changes Changes
+	var errs BuildErrors
+
+	// Directories whose listing might have changed
+	dirs := make(map[string]bool)
+	// Pages whose content changed
+	pages := make(map[string]bool)
+	// Directories whose index page changed
+	indexes := make(map[string]bool)
+	// Directories that were generated entirely
+	var trees []string
+
+	// Links to the pages around every page, to find the ones that changed
+	links := p.pageLinkHashes()
+
+	paths = slices.Clone(paths)
+	slices.Sort(paths)
+
+	for _, path := range paths {
+		if !p.isWatched(path) || isWithin(path, trees) {
+			continue
+		}
+
+		// The metadata and index page of a directory set its title in the
+		// listing of its parent
+		if filepath.Base(path) == MetaFile || p.isIndexPage(filepath.Base(path)) {
+			if dir := filepath.Dir(path); dir != p.RootPath {
+				dirs[filepath.Dir(dir)] = true
+			}
+		}
+
+		if filepath.Base(path) == MetaFile {
+			dirs[filepath.Dir(path)] = true
+			continue
+		}
+
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			// Removed assets don't affect any listing, but the pages
+			// that refer to them need to be reloaded
+			if _, wasDir := p.listing(path); !wasDir && !p.isMarkdownFile(path) {
+				if err := p.removeOutput(path); err != nil {
+					errs = append(errs, asBuildError(path, StageSave, err))
+				}
+				changes.All = true
+				continue
+			}
+
+			// The directory falls back to the plain index
+			if p.isIndexPage(filepath.Base(path)) {
+				indexes[filepath.Dir(path)] = true
+				dirs[filepath.Dir(path)] = true
+				continue
+			}
+
+			if err := p.removeOutput(path); err != nil {
+				errs = append(errs, asBuildError(path, StageSave, err))
+			}
+			dirs[filepath.Dir(path)] = true
+			changes.Structural = true
+		case err != nil:
+			errs = append(errs, asBuildError(path, StageRead, err))
+		case info.IsDir():
+			if err := p.generateTree(path); err != nil {
+				errs = appendBuildErrors(errs, path, err)
+			}
+			trees = append(trees, path)
+			changes.Structural = true
+			if path != p.RootPath {
+				dirs[filepath.Dir(path)] = true
+			}
+		case p.isIndexPage(filepath.Base(path)):
+			indexes[filepath.Dir(path)] = true
+			dirs[filepath.Dir(path)] = true
+		case p.isMarkdownFile(path):
+			pages[path] = true
+			dirs[filepath.Dir(path)] = true
+		case p.isAsset(path):
+			if err := p.copyAsset(path); err != nil {
+				errs = append(errs, asBuildError(path, "", err))
+			}
+			changes.All = true
+		}
+	}
+
+	for _, dir := range sortedKeys(dirs) {
+		if isWithin(dir, trees) {
+			continue
+		}
+
+		if _, err := os.Stat(dir); err != nil {
+			continue
+		}
+
+		files, err := p.getDirectoryListing(dir)
+		if err != nil {
+			errs = append(errs, asBuildError(dir, StageListing, err))
+			continue
+		}
+
+		if previous, ok := p.listing(dir); ok && reflect.DeepEqual(previous, files) {
+			// Only the content of the index page changed
+			if indexes[dir] {
+				if err := p.generateDir(dir); err != nil {
+					errs = append(errs, asBuildError(dir, "", err))
+				}
+				changes.Routes = append(changes.Routes, p.routeFor(dir))
+			}
+			continue
+		}
+
+		// The sidebar of every page in the directory changed
+		if err := p.generateDir(dir); err != nil {
+			errs = append(errs, asBuildError(dir, "", err))
+		}
+		changes.Structural = true
+		changes.Routes = append(changes.Routes, p.routeFor(dir))
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			errs = append(errs, asBuildError(dir, StageRead, err))
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() && p.isMarkdownFile(entry.Name()) && !p.isIgnored(entry.Name()) && !p.isIndexPage(entry.Name()) {
+				pages[filepath.Join(dir, entry.Name())] = true
+			}
+		}
+	}
+
+	// The navigation tree is part of every page
+	if p.updateNav() && !p.FlatNav {
+		if err := p.generateTree(p.RootPath); err != nil {
+			errs = appendBuildErrors(errs, p.RootPath, err)
+		}
+		trees = append(trees, p.RootPath)
+		changes.Structural = true
+		changes.All = true
+	}
+
+	// Pages whose breadcrumbs or previous and next pages changed
+	current := p.pageLinkHashes()
+	for _, path := range sortedKeys(current) {
+		if links[path] == current[path] || isWithin(path, trees) {
+			continue
+		}
+
+		files, isDir := p.listing(path)
+		if !isDir {
+			pages[path] = true
+			continue
+		}
+
+		if err := p.ensureIndexForDir(path, files); err != nil {
+			errs = append(errs, asBuildError(path, "", err))
+		}
+		if route := p.routeFor(path); !slices.Contains(changes.Routes, route) {
+			changes.Routes = append(changes.Routes, route)
+		}
+	}
+
+	for _, path := range sortedKeys(pages) {
+		if isWithin(path, trees) {
+			continue
+		}
+
+		if err := p.generatePage(path); err != nil {
+			errs = append(errs, asBuildError(path, "", err))
+			continue
+		}
+		changes.Routes = append(changes.Routes, p.routeFor(path))
+	}
+
+	if err := p.writeSearchIndex(); err != nil {
+		return changes, errors.Join(errs.orNil(), err)
+	}
+
+	if p.Precompress {
+		if err := p.precompress(); err != nil {
+			return changes, errors.Join(errs.orNil(), err)
+		}
+	}
+
+	if err := p.manifest.save(p.OutputPath); err != nil {
+		return changes, errors.Join(errs.orNil(), err)
+	}
+
+	return changes, errs.orNil()
+}
+
+// isWatched reports whether changes to path affect the output
+func (p *Parser) isWatched(path string) bool {
+	relPath, err := filepath.Rel(p.RootPath, path)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return false
+	}
+
+	if relPath == "." {
+		return true
+	}
+
+	// Any ignored or output directory along the way excludes the path
+	current := p.RootPath
+	names := strings.Split(relPath, string(filepath.Separator))
+	for i, name := range names {
+		current = filepath.Join(current, name)
+		if name == MetaFile && i == len(names)-1 {
+			continue
+		}
+		if p.isIgnored(name) || p.isOutputPath(current) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// removeOutput deletes the output generated for a source that no longer
+// exists, which can be a markdown file, an asset or a directory
+func (p *Parser) removeOutput(path string) error {
+	if p.isMarkdownFile(path) {
+		outputPath, err := p.outputPathFor(path)
+		if err != nil {
+			return err
+		}
+
+		p.forget(outputPath)
+		p.forgetSearch(path)
+		if err := os.Remove(outputPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	relPath, err := filepath.Rel(p.RootPath, path)
+	if err != nil {
+		return err
+	}
+
+	outputDir := filepath.Join(p.OutputPath, relPath)
+	p.forget(outputDir)
+	p.forgetSearchTree(path)
+
+	info, err := os.Stat(outputDir)
+	if err != nil {
+		return nil
+	}
+
+	// A copied asset
+	if !info.IsDir() {
+		return os.Remove(outputDir)
+	}
+
+	p.forgetListings(path)
+
+	return os.RemoveAll(outputDir)
+}
+
+// isWithin reports whether path is one of dirs or inside one of them
+func isWithin(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
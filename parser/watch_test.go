@@ -0,0 +1,225 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jpbruinsslot/mdex/watcher"
+)
+
+func TestRegenerate(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# B")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir), WithFlatNav(true))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changing the content of a page only renders that page
+	bOutput := filepath.Join(outputDir, "b.html")
+	before, err := os.Stat(bOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	past := before.ModTime().Add(-time.Hour)
+	if err := os.Chtimes(bOutput, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A\n\nChanged")
+	changes, err := p.Regenerate([]string{filepath.Join(rootDir, "a.md")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.Structural || !slices.Equal(changes.Routes, []string{"/a"}) {
+		t.Errorf("Expected only route '/a' to change, but got %+v", changes)
+	}
+
+	expectContains(t, filepath.Join(outputDir, "a.html"), "Changed")
+	after, err := os.Stat(bOutput)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !after.ModTime().Equal(past) {
+		t.Error("Expected unaffected page not to be rendered again")
+	}
+
+	// Adding a page renders its siblings again, since their sidebar changed
+	writeFile(t, filepath.Join(rootDir, "c.md"), "# C")
+	changes, err = p.Regenerate([]string{filepath.Join(rootDir, "c.md")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Structural || !slices.Equal(changes.Routes, []string{"/", "/a", "/b", "/c"}) {
+		t.Errorf("Expected a structural change of all routes, but got %+v", changes)
+	}
+
+	expectContains(t, filepath.Join(outputDir, "c.html"), "<h1 id=\"c\">C</h1>")
+	expectContains(t, filepath.Join(outputDir, "b.html"), `href="/c"`)
+	expectContains(t, filepath.Join(outputDir, "index.html"), `href="/c"`)
+
+	// Removing a page removes its output
+	if err := os.Remove(filepath.Join(rootDir, "c.md")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Regenerate([]string{filepath.Join(rootDir, "c.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "c.html")); !os.IsNotExist(err) {
+		t.Error("Expected output of removed page to be deleted")
+	}
+	expectNotContains(t, filepath.Join(outputDir, "b.html"), `href="/c"`)
+
+	// Adding a directory generates it entirely
+	if err := os.MkdirAll(filepath.Join(rootDir, "guide"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(rootDir, "guide", "setup.md"), "# Setup")
+	if _, err := p.Regenerate([]string{filepath.Join(rootDir, "guide"), filepath.Join(rootDir, "guide", "setup.md")}); err != nil {
+		t.Fatal(err)
+	}
+
+	expectContains(t, filepath.Join(outputDir, "guide", "setup.html"), "Setup")
+	expectContains(t, filepath.Join(outputDir, "guide", "index.html"), `href="/guide/setup"`)
+	expectContains(t, filepath.Join(outputDir, "a.html"), "guide")
+
+	// Removing a directory removes its outputs
+	if err := os.RemoveAll(filepath.Join(rootDir, "guide")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Regenerate([]string{filepath.Join(rootDir, "guide")}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(outputDir, "guide")); !os.IsNotExist(err) {
+		t.Error("Expected output of removed directory to be deleted")
+	}
+}
+
+func TestRegenerateIgnored(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := filepath.Join(rootDir, "public")
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(rootDir, "_draft.md"), "# Draft")
+	writeFile(t, filepath.Join(outputDir, "stray.md"), "# Stray")
+
+	paths := []string{
+		filepath.Join(rootDir, "_draft.md"),
+		filepath.Join(outputDir, "stray.md"),
+	}
+	if _, err := p.Regenerate(paths); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"_draft.html", "stray.html"} {
+		if _, err := os.Stat(filepath.Join(outputDir, name)); err == nil {
+			t.Errorf("Expected '%s' not to be generated", name)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	rootDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	writeFile(t, filepath.Join(rootDir, "a.md"), "# A")
+
+	p := New(NewGoldmarkParser(), WithRootPath(rootDir), WithOutputPath(outputDir))
+	if err := p.Generate(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error)
+	go func() {
+		done <- p.Watch(ctx, watcher.WithInterval(10*time.Millisecond), watcher.WithDebounce(20*time.Millisecond))
+	}()
+
+	// Give the watcher time to take its initial snapshot
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(rootDir, "b.md"), "# B")
+
+	output := filepath.Join(outputDir, "b.html")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(output); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected '%s' to be generated by the watcher, but it wasn't", output)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouteFor(t *testing.T) {
+	p := &Parser{RootPath: "/docs"}
+
+	tests := map[string]string{
+		"/docs":                 "/",
+		"/docs/guide":           "/guide/",
+		"/docs/guide/setup.md":  "/guide/setup",
+		"/docs/guide/index.md":  "/guide/",
+		"/docs/index.markdown":  "/",
+		"/docs/notes/daily.mkd": "/notes/daily",
+	}
+
+	for path, expected := range tests {
+		if route := p.routeFor(path); route != expected {
+			t.Errorf("Expected route for '%s' to be '%s', but got '%s'", path, expected, route)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectContains(t *testing.T, path, expected string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), expected) {
+		t.Errorf("Expected '%s' to contain '%s', but it didn't", path, expected)
+	}
+}
+
+func expectNotContains(t *testing.T, path, unexpected string) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(content), unexpected) {
+		t.Errorf("Expected '%s' not to contain '%s', but it did", path, unexpected)
+	}
+}
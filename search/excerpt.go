@@ -0,0 +1,65 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// excerptLength is the length in characters of the excerpt of a result
+const excerptLength = 160
+
+// excerpt returns the part of the text around the first match of one of the
+// words, or the start of the text when none matches
+func excerpt(text string, words []string) string {
+	text = strings.Join(strings.Fields(text), " ")
+
+	start := -1
+	lower := strings.ToLower(text)
+	// Positions in the lowercase text only apply when lowercasing didn't
+	// change the length
+	if len(lower) == len(text) {
+		for _, word := range words {
+			if i := strings.Index(lower, word); i >= 0 && (start < 0 || i < start) {
+				start = i
+			}
+		}
+	}
+
+	prefix := ""
+	if start > 0 {
+		// Show some context before the match, from the start of a word
+		start = max(0, start-excerptLength/4)
+		for start > 0 && !unicode.IsSpace(rune(text[start-1])) {
+			start--
+		}
+		if start > 0 {
+			prefix = "… "
+		}
+	} else {
+		start = 0
+	}
+
+	rest := text[start:]
+	if utf8.RuneCountInString(rest) <= excerptLength {
+		return prefix + rest
+	}
+
+	// Cut at the last word that fits
+	end, cut, n := 0, len(rest), 0
+	for i, r := range rest {
+		if n == excerptLength {
+			cut = i
+			break
+		}
+		if r == ' ' {
+			end = i
+		}
+		n++
+	}
+	if end == 0 {
+		end = cut
+	}
+
+	return prefix + rest[:end] + " …"
+}
@@ -0,0 +1,110 @@
+package search
+
+import (
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	titlePattern   = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+	articlePattern = regexp.MustCompile(`(?is)<article>(.*)</article>`)
+	headingPattern = regexp.MustCompile(`(?is)<h[1-6][^>]*>(.*?)</h[1-6]>`)
+	// Navigation, like breadcrumbs, and scripts are not part of the text
+	skipPattern = regexp.MustCompile(`(?is)<(nav|script|style)\b.*?</(nav|script|style)>`)
+	tagPattern  = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// ReadDir reads the pages generated by mdex in root. Hidden directories and
+// directories starting with an underscore, like the search index of the
+// browser, are skipped, and so are pages without text, like plain directory
+// indexes.
+func ReadDir(root string) ([]Document, error) {
+	var docs []Document
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := d.Name()
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if filepath.Ext(name) != ".html" {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		doc := ParseHTML(content)
+		if doc.Text == "" {
+			return nil
+		}
+
+		doc.URL = pageURL(relPath)
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// ParseHTML returns the title, headings and text of a page generated by mdex,
+// whose content is in its article element
+func ParseHTML(page []byte) Document {
+	var doc Document
+
+	if m := titlePattern.FindSubmatch(page); m != nil {
+		doc.Title = plainText(m[1])
+	}
+
+	m := articlePattern.FindSubmatch(page)
+	if m == nil {
+		return doc
+	}
+	article := skipPattern.ReplaceAll(m[1], nil)
+
+	for _, heading := range headingPattern.FindAllSubmatch(article, -1) {
+		if text := plainText(heading[1]); text != "" {
+			doc.Headings = append(doc.Headings, text)
+		}
+	}
+
+	doc.Text = plainText(article)
+	return doc
+}
+
+// plainText strips the tags from HTML, and collapses whitespace
+func plainText(b []byte) string {
+	text := html.UnescapeString(string(tagPattern.ReplaceAll(b, []byte(" "))))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// pageURL returns the site-relative URL of a generated page, like /guide/
+// for guide/index.html and /guide/setup for guide/setup.html
+func pageURL(relPath string) string {
+	route := "/" + strings.TrimSuffix(filepath.ToSlash(relPath), ".html")
+	if route == "/index" || strings.HasSuffix(route, "/index") {
+		return strings.TrimSuffix(route, "index")
+	}
+	return route
+}
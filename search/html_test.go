@@ -0,0 +1,83 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+const testPage = `<!doctype html>
+<html>
+  <head><title>Setup &amp; usage</title></head>
+  <body>
+    <nav id="sidebar"><a href="/">Home</a></nav>
+    <article>
+      <nav class="breadcrumbs"><a href="/">Home</a></nav>
+      <h1 id="setup">Setup &amp; usage</h1>
+      <p>Run <code>make install</code>.</p>
+      <h2 id="flags">Flags</h2>
+    </article>
+  </body>
+</html>`
+
+func TestParseHTML(t *testing.T) {
+	doc := ParseHTML([]byte(testPage))
+
+	if doc.Title != "Setup & usage" {
+		t.Errorf("Expected title 'Setup & usage', but got '%s'", doc.Title)
+	}
+	if expected := []string{"Setup & usage", "Flags"}; !slices.Equal(doc.Headings, expected) {
+		t.Errorf("Expected headings %v, but got %v", expected, doc.Headings)
+	}
+	if expected := "Setup & usage Run make install . Flags"; doc.Text != expected {
+		t.Errorf("Expected text '%s', but got '%s'", expected, doc.Text)
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	root := t.TempDir()
+
+	for _, dir := range []string{"guide", "_search", ".git"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"index.html", "guide/setup.html", "_search/page.html", ".git/page.html"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(testPage), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Pages without text are left out
+	if err := os.WriteFile(filepath.Join(root, "guide", "index.html"), []byte("<article></article>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual []string
+	for _, doc := range docs {
+		actual = append(actual, doc.URL)
+	}
+	if expected := []string{"/guide/setup", "/"}; !slices.Equal(actual, expected) {
+		t.Errorf("Expected pages %v, but got %v", expected, actual)
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	tests := map[string]string{
+		"index.html":       "/",
+		"guide/index.html": "/guide/",
+		"guide/setup.html": "/guide/setup",
+		"reindex.html":     "/reindex",
+	}
+
+	for relPath, expected := range tests {
+		if actual := pageURL(relPath); actual != expected {
+			t.Errorf("Expected '%s' for '%s', but got '%s'", expected, relPath, actual)
+		}
+	}
+}
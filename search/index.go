@@ -0,0 +1,372 @@
+// Package search is an in-memory full-text index of the pages of a site. It
+// ranks results with BM25, boosts matches in titles and headings, and
+// supports phrase and prefix queries.
+package search
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Parameters of BM25
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// Boosts of the terms in titles and headings, relative to the text
+const (
+	titleBoost   = 3
+	headingBoost = 2
+)
+
+// maxExpansions limits the number of terms a prefix query matches
+const maxExpansions = 50
+
+// MaxTermLength is the length in characters of the longest term that is
+// indexed, longer words are left out
+const MaxTermLength = 40
+
+// Document is a page of the site
+type Document struct {
+	// URL is the site-relative URL of the page
+	URL      string
+	Title    string
+	Headings []string
+	// Text is the plain text of the page, headings included
+	Text string
+}
+
+// Result is a page that matches a query
+type Result struct {
+	URL     string  `json:"url"`
+	Title   string  `json:"title"`
+	Excerpt string  `json:"excerpt"`
+	Score   float64 `json:"score"`
+}
+
+// Index is an inverted index of documents. It is immutable, so it can be
+// searched concurrently and replaced as a whole when the site changes.
+type Index struct {
+	docs      []Document
+	lengths   []float64
+	avgLength float64
+
+	postings map[string][]posting
+	// terms are the indexed terms in order, for prefix queries
+	terms []string
+}
+
+// posting is the occurrence of a term in a document
+type posting struct {
+	doc int
+	// freq is the frequency of the term, with matches in the title and
+	// headings boosted
+	freq float64
+	// positions of the term in the document, for phrase queries
+	positions []int
+}
+
+// New indexes the documents
+func New(docs []Document) *Index {
+	idx := &Index{
+		docs:     docs,
+		lengths:  make([]float64, len(docs)),
+		postings: make(map[string][]posting),
+	}
+
+	var total float64
+	for id, doc := range docs {
+		occurrences := make(map[string]*posting)
+		position := 0
+
+		add := func(text string, boost float64) {
+			for _, term := range Tokenize(text) {
+				p, ok := occurrences[term]
+				if !ok {
+					p = &posting{doc: id}
+					occurrences[term] = p
+				}
+				p.freq += boost
+				p.positions = append(p.positions, position)
+				position++
+			}
+
+			// Phrases don't match across fields
+			position++
+		}
+
+		add(doc.Title, titleBoost)
+		for _, heading := range doc.Headings {
+			add(heading, headingBoost)
+		}
+		add(doc.Text, 1)
+
+		for term, p := range occurrences {
+			idx.postings[term] = append(idx.postings[term], *p)
+			idx.lengths[id] += p.freq
+		}
+		total += idx.lengths[id]
+	}
+
+	if len(docs) > 0 {
+		idx.avgLength = total / float64(len(docs))
+	}
+
+	idx.terms = make([]string, 0, len(idx.postings))
+	for term := range idx.postings {
+		idx.terms = append(idx.terms, term)
+	}
+	slices.Sort(idx.terms)
+
+	return idx
+}
+
+// Len returns the number of documents in the index
+func (idx *Index) Len() int {
+	return len(idx.docs)
+}
+
+// Search returns the documents that match all terms and phrases of the
+// query, best first, up to limit, and the total number of matches. Phrases
+// are quoted, like "getting started", and terms ending with * match all
+// terms starting with them.
+func (idx *Index) Search(query string, limit int) ([]Result, int) {
+	return idx.SearchFunc(query, limit, nil)
+}
+
+// SearchFunc is like Search, but only returns the documents whose URL keep
+// reports true for, like the pages a user may access. A nil keep keeps all
+// documents.
+func (idx *Index) SearchFunc(query string, limit int, keep func(url string) bool) ([]Result, int) {
+	q := ParseQuery(query)
+	if q.empty() {
+		return nil, 0
+	}
+
+	scores := make(map[int]float64)
+	first := true
+
+	// require scores the documents that contain one of the terms, and keeps
+	// only the documents that matched all clauses so far
+	require := func(terms []string) {
+		matched := make(map[int]float64)
+		for _, term := range terms {
+			postings := idx.postings[term]
+			idf := idx.idf(len(postings))
+			for _, p := range postings {
+				matched[p.doc] += idf * idx.bm25(p)
+			}
+		}
+
+		if first {
+			scores = matched
+			first = false
+			return
+		}
+
+		for doc, score := range scores {
+			if add, ok := matched[doc]; ok {
+				scores[doc] = score + add
+			} else {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	for _, term := range q.Terms {
+		require(idx.expand(term))
+	}
+
+	for _, phrase := range q.Phrases {
+		for _, term := range phrase {
+			require([]string{term})
+		}
+
+		for doc := range scores {
+			if !idx.hasPhrase(doc, phrase) {
+				delete(scores, doc)
+			}
+		}
+	}
+
+	type match struct {
+		doc   int
+		score float64
+	}
+
+	matches := make([]match, 0, len(scores))
+	for doc, score := range scores {
+		if keep != nil && !keep(idx.docs[doc].URL) {
+			continue
+		}
+		matches = append(matches, match{doc: doc, score: score})
+	}
+
+	slices.SortFunc(matches, func(x, y match) int {
+		if c := cmp.Compare(y.score, x.score); c != 0 {
+			return c
+		}
+		return strings.Compare(idx.docs[x.doc].URL, idx.docs[y.doc].URL)
+	})
+
+	total := len(matches)
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	words := q.words()
+	results := make([]Result, len(matches))
+	for i, m := range matches {
+		doc := idx.docs[m.doc]
+		results[i] = Result{
+			URL:     doc.URL,
+			Title:   doc.Title,
+			Excerpt: excerpt(doc.Text, words),
+			Score:   m.score,
+		}
+	}
+
+	return results, total
+}
+
+// expand returns the indexed terms a query term matches
+func (idx *Index) expand(term Term) []string {
+	if !term.Prefix {
+		return []string{term.Text}
+	}
+
+	var terms []string
+	for i := sort.SearchStrings(idx.terms, term.Text); i < len(idx.terms); i++ {
+		if !strings.HasPrefix(idx.terms[i], term.Text) || len(terms) == maxExpansions {
+			break
+		}
+		terms = append(terms, idx.terms[i])
+	}
+	return terms
+}
+
+func (idx *Index) idf(docFreq int) float64 {
+	n := float64(len(idx.docs))
+	df := float64(docFreq)
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (idx *Index) bm25(p posting) float64 {
+	norm := 1.0
+	if idx.avgLength > 0 {
+		norm = 1 - b + b*idx.lengths[p.doc]/idx.avgLength
+	}
+	return p.freq * (k1 + 1) / (p.freq + k1*norm)
+}
+
+// hasPhrase reports whether the terms appear next to each other in the
+// document
+func (idx *Index) hasPhrase(doc int, phrase []string) bool {
+	positions := make([][]int, len(phrase))
+	for i, term := range phrase {
+		for _, p := range idx.postings[term] {
+			if p.doc == doc {
+				positions[i] = p.positions
+				break
+			}
+		}
+	}
+
+	for _, start := range positions[0] {
+		found := true
+		for i := 1; i < len(phrase); i++ {
+			if _, ok := slices.BinarySearch(positions[i], start+i); !ok {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// Term is a term of a query
+type Term struct {
+	Text string
+	// Prefix is set when the term matches all terms starting with it
+	Prefix bool
+}
+
+// Query is a parsed search query
+type Query struct {
+	Terms   []Term
+	Phrases [][]string
+}
+
+// ParseQuery splits a query in terms and quoted phrases. A phrase of a
+// single term is a term.
+func ParseQuery(query string) Query {
+	var q Query
+
+	for i, part := range strings.Split(query, `"`) {
+		// Parts at odd positions are between quotes
+		if i%2 == 1 {
+			switch phrase := Tokenize(part); len(phrase) {
+			case 0:
+			case 1:
+				q.Terms = append(q.Terms, Term{Text: phrase[0]})
+			default:
+				q.Phrases = append(q.Phrases, phrase)
+			}
+			continue
+		}
+
+		for _, field := range strings.Fields(part) {
+			terms := Tokenize(field)
+			for _, term := range terms {
+				q.Terms = append(q.Terms, Term{Text: term})
+			}
+			// Only a term of this field can be a prefix, not one before it
+			if strings.HasSuffix(field, "*") && len(terms) > 0 {
+				q.Terms[len(q.Terms)-1].Prefix = true
+			}
+		}
+	}
+
+	return q
+}
+
+func (q Query) empty() bool {
+	return len(q.Terms) == 0 && len(q.Phrases) == 0
+}
+
+// words returns all terms of the query
+func (q Query) words() []string {
+	var words []string
+	for _, term := range q.Terms {
+		words = append(words, term.Text)
+	}
+	for _, phrase := range q.Phrases {
+		words = append(words, phrase...)
+	}
+	return words
+}
+
+// Tokenize splits text in lowercase terms of letters and digits. Terms of a
+// single character are left out, and so are terms longer than MaxTermLength.
+func Tokenize(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := fields[:0]
+	for _, field := range fields {
+		if n := utf8.RuneCountInString(field); n >= 2 && n <= MaxTermLength {
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,146 @@
+package search
+
+import (
+	"slices"
+	"testing"
+)
+
+func testIndex() *Index {
+	return New([]Document{
+		{
+			URL:      "/install",
+			Title:    "Installation",
+			Headings: []string{"Getting started"},
+			Text:     "Installation Getting started Download the binary and run it.",
+		},
+		{
+			URL:   "/config",
+			Title: "Configuration",
+			Text:  "Configuration The server is started with a config file. Getting the config right takes time.",
+		},
+		{
+			URL:   "/faq",
+			Title: "FAQ",
+			Text:  "FAQ Is the binary started as a server? Yes, the server is started.",
+		},
+	})
+}
+
+func urls(results []Result) []string {
+	var urls []string
+	for _, result := range results {
+		urls = append(urls, result.URL)
+	}
+	return urls
+}
+
+func TestSearch(t *testing.T) {
+	idx := testIndex()
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"installation", []string{"/install"}},
+		// Shorter pages rank above longer ones
+		{"binary", []string{"/faq", "/install"}},
+		// All terms have to match
+		{"binary server", []string{"/faq"}},
+		// Terms in headings rank above terms in the text only
+		{"getting started", []string{"/install", "/config"}},
+		// Phrases have to match in order
+		{`"getting started"`, []string{"/install"}},
+		{`"started getting"`, nil},
+		// Prefixes match longer terms
+		{"conf*", []string{"/config"}},
+		{"conf", nil},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		results, total := idx.Search(test.query, 10)
+		if actual := urls(results); !slices.Equal(actual, test.expected) {
+			t.Errorf("Expected %v for '%s', but got %v", test.expected, test.query, actual)
+		}
+		if total != len(test.expected) {
+			t.Errorf("Expected a total of %d for '%s', but got %d", len(test.expected), test.query, total)
+		}
+	}
+}
+
+func TestSearchBoost(t *testing.T) {
+	idx := New([]Document{
+		{URL: "/text", Title: "One", Text: "One two three deploy"},
+		{URL: "/heading", Title: "Two", Headings: []string{"Deploy"}, Text: "Two three four deploy"},
+		{URL: "/title", Title: "Deploy", Text: "Deploy three four five"},
+	})
+
+	// Matches in the title rank above matches in headings, which rank above
+	// matches in the text
+	results, _ := idx.Search("deploy", 10)
+	if expected := []string{"/title", "/heading", "/text"}; !slices.Equal(urls(results), expected) {
+		t.Errorf("Expected %v, but got %v", expected, urls(results))
+	}
+}
+
+func TestSearchLimit(t *testing.T) {
+	results, total := testIndex().Search("started", 1)
+	if len(results) != 1 || total != 3 {
+		t.Errorf("Expected 1 of 3 results, but got %d of %d", len(results), total)
+	}
+}
+
+func TestSearchFunc(t *testing.T) {
+	keep := func(url string) bool {
+		return url != "/install"
+	}
+
+	results, total := testIndex().SearchFunc("started", 10, keep)
+	if total != 2 {
+		t.Errorf("Expected 2 results, but got %d", total)
+	}
+	for _, result := range results {
+		if result.URL == "/install" {
+			t.Error("Expected /install to be left out")
+		}
+	}
+}
+
+func TestSearchExcerpt(t *testing.T) {
+	results, _ := testIndex().Search("download", 10)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, but got %d", len(results))
+	}
+
+	expected := "Installation Getting started Download the binary and run it."
+	if results[0].Excerpt != expected {
+		t.Errorf("Expected excerpt '%s', but got '%s'", expected, results[0].Excerpt)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q := ParseQuery(`install "getting started" conf* "server"`)
+
+	expectedTerms := []Term{{Text: "install"}, {Text: "conf", Prefix: true}, {Text: "server"}}
+	if !slices.Equal(q.Terms, expectedTerms) {
+		t.Errorf("Expected terms %v, but got %v", expectedTerms, q.Terms)
+	}
+	if len(q.Phrases) != 1 || !slices.Equal(q.Phrases[0], []string{"getting", "started"}) {
+		t.Errorf("Expected the phrase [getting started], but got %v", q.Phrases)
+	}
+
+	// A field without terms doesn't make the term before it a prefix
+	for _, query := range []string{"go a*", "go *"} {
+		expectedTerms = []Term{{Text: "go"}}
+		if q := ParseQuery(query); !slices.Equal(q.Terms, expectedTerms) {
+			t.Errorf("Expected terms %v for '%s', but got %v", expectedTerms, query, q.Terms)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	expected := []string{"getting", "started", "with", "mdex", "día", "v2"}
+	if actual := Tokenize("Getting-started with **mdex**, a día v2!"); !slices.Equal(actual, expected) {
+		t.Errorf("Expected %v, but got %v", expected, actual)
+	}
+}
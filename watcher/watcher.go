@@ -0,0 +1,271 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Op describes the kind of change to a path
+type Op uint8
+
+const (
+	Create Op = iota + 1
+	Write
+	Remove
+)
+
+func (op Op) String() string {
+	switch op {
+	case Create:
+		return "create"
+	case Write:
+		return "write"
+	case Remove:
+		return "remove"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a file or directory. A rename is reported as the
+// removal of the old path and the creation of the new one.
+type Event struct {
+	Path  string
+	Op    Op
+	IsDir bool
+}
+
+// SkipFunc reports whether a path should not be watched. When it returns true
+// for a directory, the whole directory is skipped.
+type SkipFunc func(path string, d fs.DirEntry) bool
+
+// Watcher detects changes in a directory tree by polling it. It doesn't rely
+// on any operating system specific notification mechanism.
+type Watcher struct {
+	Root     string
+	Interval time.Duration
+	Debounce time.Duration
+	Skip     SkipFunc
+	Logger   *slog.Logger
+
+	files map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+type Option func(*Watcher)
+
+// WithInterval sets how often the tree is polled for changes
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.Interval = interval
+	}
+}
+
+// WithDebounce sets how long the tree has to be quiet before the collected
+// changes are reported, so a burst of saves results in a single callback
+func WithDebounce(debounce time.Duration) Option {
+	return func(w *Watcher) {
+		w.Debounce = debounce
+	}
+}
+
+// WithSkip sets the function that decides which paths are not watched
+func WithSkip(skip SkipFunc) Option {
+	return func(w *Watcher) {
+		w.Skip = skip
+	}
+}
+
+// WithLogger sets the logger that polling errors are reported to
+func WithLogger(logger *slog.Logger) Option {
+	return func(w *Watcher) {
+		w.Logger = logger
+	}
+}
+
+func New(root string, opts ...Option) *Watcher {
+	w := &Watcher{
+		Root:     root,
+		Interval: 500 * time.Millisecond,
+		Debounce: 300 * time.Millisecond,
+		Skip:     func(string, fs.DirEntry) bool { return false },
+		Logger:   slog.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Run polls the tree until the context is cancelled, and calls handle with
+// the collected events once the tree has been quiet for the debounce period.
+// Polls that fail are logged and tried again on the next tick, so the tree
+// can be missing for a moment, like while it is replaced.
+func (w *Watcher) Run(ctx context.Context, handle func([]Event)) error {
+	files, err := w.scan()
+	if err != nil {
+		return err
+	}
+	w.files = files
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	pending := make(map[string]Event)
+	var lastChange time.Time
+	var failing bool
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			events, err := w.Poll()
+			if err != nil {
+				// Logged once, instead of on every tick until it recovers
+				if !failing {
+					w.Logger.Error("Polling for changes failed", "root", w.Root, "error", err)
+					failing = true
+				}
+				continue
+			}
+			if failing {
+				w.Logger.Info("Polling for changes recovered", "root", w.Root)
+				failing = false
+			}
+
+			if len(events) > 0 {
+				merge(pending, events)
+				lastChange = now
+			}
+
+			if len(pending) > 0 && now.Sub(lastChange) >= w.Debounce {
+				handle(sortedEvents(pending))
+				clear(pending)
+			}
+		}
+	}
+}
+
+// Poll scans the tree once and returns the changes since the previous scan
+func (w *Watcher) Poll() ([]Event, error) {
+	files, err := w.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+
+	for path, state := range files {
+		previous, ok := w.files[path]
+		switch {
+		case !ok:
+			events = append(events, Event{Path: path, Op: Create, IsDir: state.isDir})
+		case previous.isDir != state.isDir:
+			events = append(events,
+				Event{Path: path, Op: Remove, IsDir: previous.isDir},
+				Event{Path: path, Op: Create, IsDir: state.isDir},
+			)
+		case !state.isDir && (!previous.modTime.Equal(state.modTime) || previous.size != state.size):
+			events = append(events, Event{Path: path, Op: Write})
+		}
+	}
+
+	for path, state := range w.files {
+		if _, ok := files[path]; !ok {
+			events = append(events, Event{Path: path, Op: Remove, IsDir: state.isDir})
+		}
+	}
+
+	w.files = files
+	return events, nil
+}
+
+func (w *Watcher) scan() (map[string]fileState, error) {
+	files := make(map[string]fileState)
+
+	err := filepath.WalkDir(w.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files can disappear while walking the tree
+			if path != w.Root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if path != w.Root && w.Skip(path, d) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		files[path] = fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			isDir:   d.IsDir(),
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// merge adds events to the pending events, folding multiple changes to the
+// same path into one
+func merge(pending map[string]Event, events []Event) {
+	for _, event := range events {
+		previous, ok := pending[event.Path]
+		if !ok {
+			pending[event.Path] = event
+			continue
+		}
+
+		switch {
+		case previous.Op == Create && event.Op == Write:
+			// Still a new file
+		case previous.Op == Create && event.Op == Remove:
+			// Never seen by the handler
+			delete(pending, event.Path)
+		case previous.Op == Remove && event.Op == Create && previous.IsDir == event.IsDir:
+			// Replaced, as editors do when saving atomically
+			pending[event.Path] = Event{Path: event.Path, Op: Write, IsDir: event.IsDir}
+		default:
+			pending[event.Path] = event
+		}
+	}
+}
+
+func sortedEvents(pending map[string]Event) []Event {
+	events := make([]Event, 0, len(pending))
+	for _, event := range pending {
+		events = append(events, event)
+	}
+
+	slices.SortFunc(events, func(a, b Event) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	return events
+}
@@ -0,0 +1,256 @@
+package watcher
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPoll(t *testing.T) {
+	root := t.TempDir()
+
+	existing := filepath.Join(root, "existing.md")
+	if err := os.WriteFile(existing, []byte("# Existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(root)
+	if _, err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// No changes
+	events, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("Expected no events, but got %v", events)
+	}
+
+	// Create
+	created := filepath.Join(root, "created.md")
+	if err := os.WriteFile(created, []byte("# Created"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, w, Event{Path: created, Op: Create})
+
+	// Write
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(existing, later, later); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, w, Event{Path: existing, Op: Write})
+
+	// Rename
+	renamed := filepath.Join(root, "renamed.md")
+	if err := os.Rename(created, renamed); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, w, Event{Path: created, Op: Remove}, Event{Path: renamed, Op: Create})
+
+	// Remove
+	if err := os.Remove(existing); err != nil {
+		t.Fatal(err)
+	}
+	expectEvents(t, w, Event{Path: existing, Op: Remove})
+}
+
+func TestPollSkip(t *testing.T) {
+	root := t.TempDir()
+
+	w := New(root, WithSkip(func(path string, d fs.DirEntry) bool {
+		return d.Name() == "skipped"
+	}))
+	if _, err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Mkdir(filepath.Join(root, "skipped"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "skipped", "file.md"), []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events for skipped directory, but got %v", events)
+	}
+}
+
+func TestRunDebounce(t *testing.T) {
+	root := t.TempDir()
+
+	w := New(root, WithInterval(10*time.Millisecond), WithDebounce(50*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan []Event, 10)
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, func(events []Event) {
+			batches <- events
+		})
+	}()
+
+	// Give the watcher time to take its initial snapshot
+	time.Sleep(30 * time.Millisecond)
+
+	// A burst of saves to the same file
+	path := filepath.Join(root, "burst.md")
+	for i := range 5 {
+		if err := os.WriteFile(path, []byte(string(rune('a'+i))), 0644); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	select {
+	case events := <-batches:
+		if len(events) != 1 || events[0].Path != path || events[0].Op != Create {
+			t.Errorf("Expected a single create event for '%s', but got %v", path, events)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected events to be reported, but they weren't")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunPollError(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "docs")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(root, WithInterval(10*time.Millisecond), WithDebounce(20*time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batches := make(chan []Event, 10)
+	done := make(chan error)
+	go func() {
+		done <- w.Run(ctx, func(events []Event) {
+			batches <- events
+		})
+	}()
+
+	// Give the watcher time to take its initial snapshot
+	time.Sleep(30 * time.Millisecond)
+
+	// The tree is missing for a moment, like while it is replaced
+	moved := filepath.Join(parent, "moved")
+	if err := os.Rename(root, moved); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	select {
+	case err := <-done:
+		t.Fatalf("Expected the watcher to keep polling, but it stopped with %v", err)
+	default:
+	}
+
+	if err := os.Rename(moved, root); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(root, "after.md")
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case events := <-batches:
+		if len(events) != 1 || events[0].Path != path || events[0].Op != Create {
+			t.Errorf("Expected a single create event for '%s', but got %v", path, events)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected events to be reported, but they weren't")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   []Event
+		expected []Event
+	}{
+		{
+			name:     "create then write",
+			events:   []Event{{Path: "a", Op: Create}, {Path: "a", Op: Write}},
+			expected: []Event{{Path: "a", Op: Create}},
+		},
+		{
+			name:     "create then remove",
+			events:   []Event{{Path: "a", Op: Create}, {Path: "a", Op: Remove}},
+			expected: []Event{},
+		},
+		{
+			name:     "remove then create",
+			events:   []Event{{Path: "a", Op: Remove}, {Path: "a", Op: Create}},
+			expected: []Event{{Path: "a", Op: Write}},
+		},
+		{
+			name:     "write then remove",
+			events:   []Event{{Path: "a", Op: Write}, {Path: "a", Op: Remove}},
+			expected: []Event{{Path: "a", Op: Remove}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending := make(map[string]Event)
+			merge(pending, tt.events)
+
+			events := sortedEvents(pending)
+			if len(events) != len(tt.expected) {
+				t.Fatalf("Expected %v, but got %v", tt.expected, events)
+			}
+			for i := range events {
+				if events[i] != tt.expected[i] {
+					t.Errorf("Expected %v, but got %v", tt.expected[i], events[i])
+				}
+			}
+		})
+	}
+}
+
+func expectEvents(t *testing.T, w *Watcher, expected ...Event) {
+	t.Helper()
+
+	events, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending := make(map[string]Event)
+	merge(pending, events)
+	events = sortedEvents(pending)
+
+	if len(events) != len(expected) {
+		t.Fatalf("Expected events %v, but got %v", expected, events)
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("Expected event %v, but got %v", expected[i], events[i])
+		}
+	}
+}